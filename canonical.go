@@ -0,0 +1,193 @@
+package jsonresponse
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Canonical sets flag that indicates that response body is encoded in
+// canonical form defined by RFC 8785 (JSON Canonicalization Scheme), which
+// is byte-stable, so it can be signed or hashed. Keys of all objects are
+// sorted, numbers are formatted as in ECMAScript and strings are escaped
+// minimally. Body is not indented and has no trailing newline. Numbers are
+// IEEE 754 doubles in canonical form, so integers outside of ±2^53 lose
+// precision, unless they are encoded as strings (see NumberPolicy).
+func (r Response) Canonical() Response {
+	r.canonical = true
+	return r
+}
+
+// canonical wraps body that is encoded in canonical form.
+type canonical struct {
+	data interface{}
+}
+
+// encodeCanonical writes data in canonical form to writer.
+func encodeCanonical(w io.Writer, data interface{}, indent bool, s *settings) error {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := encode(buf, data, false, s); err != nil {
+		return err
+	}
+	b, err := Canonicalize(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// Canonicalize returns canonical form of JSON document as defined by RFC 8785
+// (JSON Canonicalization Scheme). Error is returned if data is not valid JSON,
+// if it contains objects with duplicate keys or numbers that are not finite
+// doubles.
+func Canonicalize(data []byte) ([]byte, error) {
+	if !utf8.Valid(data) {
+		return nil, errors.New("jsonresponse: canonical JSON has to be valid UTF-8")
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	b, err := appendCanonical(nil, dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("jsonresponse: unexpected data after JSON value")
+	}
+	return b, nil
+}
+
+// appendCanonical reads single value from decoder and appends its canonical
+// form to b.
+func appendCanonical(b []byte, dec *json.Decoder) ([]byte, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '[' {
+			return appendCanonicalArray(b, dec)
+		}
+		return appendCanonicalObject(b, dec)
+	case string:
+		return appendCanonicalString(b, t), nil
+	case json.Number:
+		f, err := strconv.ParseFloat(string(t), 64)
+		if err != nil || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("jsonresponse: number %s can not be represented in canonical JSON", t)
+		}
+		if f == 0 {
+			// negative zero is written as 0
+			return append(b, '0'), nil
+		}
+		return appendFloat(b, f, 64), nil
+	case bool:
+		return strconv.AppendBool(b, t), nil
+	}
+	return append(b, "null"...), nil
+}
+
+func appendCanonicalArray(b []byte, dec *json.Decoder) ([]byte, error) {
+	b = append(b, '[')
+	for i := 0; dec.More(); i++ {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		var err error
+		if b, err = appendCanonical(b, dec); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return append(b, ']'), nil
+}
+
+type canonicalMember struct {
+	key   []uint16
+	name  string
+	value []byte
+}
+
+func appendCanonicalObject(b []byte, dec *json.Decoder) ([]byte, error) {
+	var members []canonicalMember
+	seen := map[string]bool{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		name := tok.(string)
+		if seen[name] {
+			return nil, fmt.Errorf("jsonresponse: duplicate key %q in canonical JSON", name)
+		}
+		seen[name] = true
+		value, err := appendCanonical(nil, dec)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, canonicalMember{key: utf16.Encode([]rune(name)), name: name, value: value})
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	// keys are sorted by their UTF-16 code units
+	sort.Slice(members, func(i, j int) bool {
+		a, c := members[i].key, members[j].key
+		for k := 0; k < len(a) && k < len(c); k++ {
+			if a[k] != c[k] {
+				return a[k] < c[k]
+			}
+		}
+		return len(a) < len(c)
+	})
+	b = append(b, '{')
+	for i, m := range members {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendCanonicalString(b, m.name)
+		b = append(b, ':')
+		b = append(b, m.value...)
+	}
+	return append(b, '}'), nil
+}
+
+// appendCanonicalString appends string with only characters that have to be
+// escaped in JSON escaped, using short escapes where they exist.
+func appendCanonicalString(b []byte, s string) []byte {
+	b = append(b, '"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b = append(b, '\\', c)
+		case c == '\b':
+			b = append(b, '\\', 'b')
+		case c == '\f':
+			b = append(b, '\\', 'f')
+		case c == '\n':
+			b = append(b, '\\', 'n')
+		case c == '\r':
+			b = append(b, '\\', 'r')
+		case c == '\t':
+			b = append(b, '\\', 't')
+		case c < 0x20:
+			b = append(b, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+		default:
+			b = append(b, c)
+		}
+	}
+	return append(b, '"')
+}
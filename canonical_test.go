@@ -0,0 +1,67 @@
+package jsonresponse
+
+import (
+	"fmt"
+	"math"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	for input, expected := range map[string]string{
+		// examples from RFC 8785
+		`{"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+		  "string": "€$\u000F\u000aA'B\u0022\u005c\\\u0022\/",
+		  "literals": [null, true, false]}`: `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],` +
+			`"string":"€$\u000f\nA'B\"\\\\\"/"}`,
+		`{"€": "Euro Sign", "\r": "Carriage Return", "\ufb33": "Hebrew Letter Dalet With Dagesh",
+		  "1": "One", "😀": "Emoji: Grinning Face", "\u0080": "Control", "ö": "Latin Small Letter O With Diaeresis"}`: `{"\r":"Carriage Return","1":"One","` + "\u0080" + `":"Control","ö":"Latin Small Letter O With Diaeresis",` +
+			`"€":"Euro Sign","😀":"Emoji: Grinning Face","` + "\ufb33" + `":"Hebrew Letter Dalet With Dagesh"}`,
+		`[-0, 1e21, 1e-7, 123456789012345678901, "<&>", " "]`: `[0,1e+21,1e-7,123456789012345680000,"<&>","` + " " + `"]`,
+		` {"b": {"d": 1, "c": 2}, "a": []} `:                  `{"a":[],"b":{"c":2,"d":1}}`,
+	} {
+		got, err := Canonicalize([]byte(input))
+		if err != nil || string(got) != expected {
+			fmt.Printf("Canonical form of %s\nexpected %s\nbut got  %s (%v)\n", input, expected, got, err)
+			t.Fail()
+		}
+	}
+
+	for _, input := range []string{`{"a":1,"a":2}`, `1e400`, `[1,]`, `{} {}`, "\"\xff\""} {
+		if _, err := Canonicalize([]byte(input)); err == nil {
+			fmt.Printf("Expected error for %s\n", input)
+			t.Fail()
+		}
+	}
+}
+
+type canonicalUser struct {
+	Name  string  `json:"name"`
+	Email string  `json:"email"`
+	Score float64 `json:"score"`
+	HTML  string  `json:"html"`
+}
+
+func TestCanonicalResponse(t *testing.T) {
+	SetIndent(true)
+	defer SetIndent(false)
+	expected := `{"data":{"email":"a@b.c","html":"<b>","name":"A","score":1e+21}}`
+	for _, fast := range []bool{false, true} {
+		SetFastEncoder(fast)
+		recorder := httptest.NewRecorder()
+		New(canonicalUser{Name: "A", Email: "a@b.c", Score: 1e21, HTML: "<b>"}).Canonical().OK(recorder)
+		if recorder.Body.String() != expected || recorder.Header().Get("Content-Length") != fmt.Sprint(len(expected)) {
+			fmt.Printf("Expected %s\nbut got  %s\n", expected, recorder.Body.String())
+			t.Fail()
+		}
+	}
+	SetFastEncoder(false)
+
+	defer func() {
+		if recover() == nil {
+			fmt.Println("Expected canonical encoding of NaN to fail")
+			t.Fail()
+		}
+	}()
+	New(math.NaN()).Canonical().OK(httptest.NewRecorder())
+}
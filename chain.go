@@ -0,0 +1,117 @@
+package jsonresponse
+
+// TransformerMiddleware wraps transformer and returns new one, which can
+// modify headers and result of wrapped transformer. Middleware is applied
+// to transformer via WrapTransformer function.
+type TransformerMiddleware func(next ResponseTransformer) ResponseTransformer
+
+// ChainTransformers creates transformer that calls all provided transformers
+// one after another. Result of each transformer is used as data for next one,
+// so last transformer in chain produces final result.
+// Headers from all transformers are merged, and headers from later transformer
+// override headers with same name from earlier ones. Headers set on response
+// itself still override all of them.
+func ChainTransformers(transformers ...ResponseTransformer) ResponseTransformer {
+	return ResponseTransformer(func(resp Response, httpCode int) (headers map[string]string, result interface{}) {
+		headers = map[string]string{}
+		result = resp.Data
+		for _, t := range transformers {
+			h, r := t(resp, httpCode)
+			for k, v := range h {
+				headers[k] = v
+			}
+			result = r
+			resp.Data = r
+		}
+		return headers, result
+	})
+}
+
+// WrapTransformer applies provided middleware to transformer. First middleware
+// is outermost one, meaning that it sees result of all other middleware and
+// that headers it sets have precedence over headers set by inner middleware
+// and by transformer itself.
+func WrapTransformer(t ResponseTransformer, middleware ...TransformerMiddleware) ResponseTransformer {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		t = middleware[i](t)
+	}
+	return t
+}
+
+// AddMeta returns middleware that adds key with value to "meta" object of
+// result. Result of wrapped transformer has to be map (like one returned
+// by default transformer), otherwise it is returned unchanged.
+func AddMeta(key string, value interface{}) TransformerMiddleware {
+	return func(next ResponseTransformer) ResponseTransformer {
+		return ResponseTransformer(func(resp Response, httpCode int) (headers map[string]string, result interface{}) {
+			headers, result = next(resp, httpCode)
+			if m, ok := result.(map[string]interface{}); ok {
+				result = withMeta(resp.metaField(), m, map[string]interface{}{key: value})
+			}
+			return headers, result
+		})
+	}
+}
+
+// AddHeader returns middleware that adds header to headers returned by
+// wrapped transformer, overriding header with same name if it already exists.
+func AddHeader(key, value string) TransformerMiddleware {
+	return func(next ResponseTransformer) ResponseTransformer {
+		return ResponseTransformer(func(resp Response, httpCode int) (headers map[string]string, result interface{}) {
+			h, result := next(resp, httpCode)
+			headers = make(map[string]string, len(h)+1)
+			for k, v := range h {
+				headers[k] = v
+			}
+			headers[key] = value
+			return headers, result
+		})
+	}
+}
+
+// RenameField returns middleware that renames top level field of result
+// from "from" to "to". Result of wrapped transformer has to be map, otherwise
+// it is returned unchanged.
+func RenameField(from, to string) TransformerMiddleware {
+	return func(next ResponseTransformer) ResponseTransformer {
+		return ResponseTransformer(func(resp Response, httpCode int) (headers map[string]string, result interface{}) {
+			headers, result = next(resp, httpCode)
+			m, ok := result.(map[string]interface{})
+			if !ok {
+				return headers, result
+			}
+			if _, ok := m[from]; !ok {
+				return headers, result
+			}
+			renamed := make(map[string]interface{}, len(m))
+			for k, v := range m {
+				if k == from {
+					k = to
+				}
+				renamed[k] = v
+			}
+			return headers, renamed
+		})
+	}
+}
+
+// withMeta returns copy of result with values merged into its meta object,
+// which is in provided field. Existing meta values with same keys are
+// overridden.
+func withMeta(field string, result map[string]interface{}, values map[string]interface{}) map[string]interface{} {
+	meta := map[string]interface{}{}
+	if existing, ok := result[field].(map[string]interface{}); ok {
+		for k, v := range existing {
+			meta[k] = v
+		}
+	}
+	for k, v := range values {
+		meta[k] = v
+	}
+	merged := make(map[string]interface{}, len(result)+1)
+	for k, v := range result {
+		merged[k] = v
+	}
+	merged[field] = meta
+	return merged
+}
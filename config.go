@@ -1,53 +1,242 @@
 package jsonresponse
 
-import "sync"
-
-var (
-	transformerLock = &sync.Mutex{}
+import (
+	"sync"
+	"sync/atomic"
+)
 
+// settings is snapshot of configuration of package. Snapshot is never
+// modified after it is stored, setters store modified copy of it instead, so
+// response can read all settings it needs from single snapshot without locks.
+type settings struct {
 	// Transformer for response. Default implementation wraps response in
 	// SBG envelope (with status and message).
-	transformer ResponseTransformer = defaultTransformer
-)
+	transformer transformerEntry
 
-var (
-	contentTypeHeaderLock = &sync.Mutex{}
+	// Transformers for specific status classes and codes. When response is
+	// sent, most specific one is used and global transformer is used only if
+	// none of them is set.
+	classTransformers map[StatusClass]transformerEntry
+	codeTransformers  map[int]transformerEntry
 
 	// Default Content-Type header for Json responses.
-	defaultContentTypeHeader = "application/json; charset=utf-8"
-)
-
-var (
-	indentLock = &sync.Mutex{}
+	contentType string
 
 	// indent sets flag that indicates that all json responses will be
 	// indented before returned. This can be useful for debugging when
 	// consumer of JSON response is developer and not other service.
-	indent = false
+	indent bool
+
+	// keyNaming is casing of keys of fields without name in json tag.
+	keyNaming keyNaming
+
+	// Name of query parameter with list of fields that client wants in
+	// response (e.g. ?fields=id,name,owner.email). Empty string disables
+	// projection of fields.
+	fieldsParam string
+
+	// Maximum size of buffered response in bytes, not positive value
+	// means that there is no limit.
+	bufferLimit int
+
+	// What happens with responses larger than buffer limit.
+	oversizeMode OversizeMode
+
+	// fastEncoder enables encoder with cached encoding plan for each type
+	// instead of encoding/json.
+	fastEncoder bool
+
+	// hooks invoked for every response, see Hooks.
+	hooks []Hooks
+
+	// timingsInMeta includes server timing metrics in "meta" object of
+	// envelope, in addition to Server-Timing header.
+	timingsInMeta bool
+
+	// Name of header with ID of request, see RequestIDMiddleware.
+	requestIDHeader string
+
+	// Name of field of envelope with metadata, see Meta.
+	metaField string
+
+	// metaProviders add values to metadata of every response.
+	metaProviders []MetaProvider
+
+	// numberPolicy defines how numbers are encoded, see NumberPolicy.
+	numberPolicy NumberPolicy
+
+	// signing configures Content-Digest and signature headers of responses.
+	signing SigningOptions
+
+	// jws configures JWS of response bodies.
+	jws JWSOptions
+
+	// redaction configures redaction of sensitive fields, see SetRedactedKeys.
+	redaction redactionOptions
+}
+
+var (
+	// configLock serializes setters, readers only load snapshot.
+	configLock = &sync.Mutex{}
+
+	// config holds current *settings.
+	config atomic.Value
 )
 
+func init() {
+	config.Store(&settings{
+		transformer:       transformerEntry{transform: defaultEnvelope.Transformer, describer: defaultEnvelope},
+		classTransformers: map[StatusClass]transformerEntry{},
+		codeTransformers:  map[int]transformerEntry{},
+		contentType:       "application/json; charset=utf-8",
+		fieldsParam:       "fields",
+		oversizeMode:      OversizeError,
+		requestIDHeader:   "X-Request-ID",
+		metaField:         "meta",
+	})
+}
+
+// current returns current snapshot of configuration.
+func current() *settings {
+	return config.Load().(*settings)
+}
+
+// update stores copy of current configuration modified by provided function.
+func update(f func(s *settings)) {
+	configLock.Lock()
+	defer configLock.Unlock()
+	s := *current()
+	f(&s)
+	config.Store(&s)
+}
+
 // SetTransformer sets function that will process response additionally.
 // Default implementation just wraps response value in map with key "data".
 func SetTransformer(t ResponseTransformer) {
-	transformerLock.Lock()
-	defer transformerLock.Unlock()
-	transformer = t
+	update(func(s *settings) {
+		s.transformer = transformerEntry{transform: AdaptTransformer(t)}
+	})
+}
+
+// SetRequestTransformer sets function that will process response additionally,
+// same as SetTransformer, except that provided function also receives request.
+func SetRequestTransformer(t RequestTransformer) {
+	update(func(s *settings) {
+		s.transformer = transformerEntry{transform: t}
+	})
+}
+
+// SetEnvelope sets transformer same as SetRequestTransformer, except that
+// transformer declares where it places data of response.
+func SetEnvelope(e Envelope) {
+	update(func(s *settings) {
+		s.transformer = transformerEntry{transform: e.Transformer, describer: e}
+	})
+}
+
+// SetTransformerFor sets transformer used only for responses with status
+// code in provided class, e.g. SetTransformerFor(StatusClassClientError, t)
+// for all 4xx responses. It has precedence over transformer set via
+// SetTransformer.
+func SetTransformerFor(class StatusClass, t ResponseTransformer) {
+	SetRequestTransformerFor(class, AdaptTransformer(t))
+}
+
+// SetRequestTransformerFor is same as SetTransformerFor, except that provided
+// function also receives request.
+func SetRequestTransformerFor(class StatusClass, t RequestTransformer) {
+	setClassTransformer(class, transformerEntry{transform: t})
+}
+
+// SetEnvelopeFor is same as SetRequestTransformerFor, except that transformer
+// declares where it places data of response.
+func SetEnvelopeFor(class StatusClass, e Envelope) {
+	setClassTransformer(class, transformerEntry{transform: e.Transformer, describer: e})
+}
+
+// SetTransformerForCode sets transformer used only for responses with exact
+// status code. It has precedence over transformers set for status class and
+// over transformer set via SetTransformer.
+func SetTransformerForCode(httpCode int, t ResponseTransformer) {
+	SetRequestTransformerForCode(httpCode, AdaptTransformer(t))
+}
+
+// SetRequestTransformerForCode is same as SetTransformerForCode, except that
+// provided function also receives request.
+func SetRequestTransformerForCode(httpCode int, t RequestTransformer) {
+	setCodeTransformer(httpCode, transformerEntry{transform: t})
 }
 
-// ResetTransformer resets current transformer to default one.
+// SetEnvelopeForCode is same as SetRequestTransformerForCode, except that
+// transformer declares where it places data of response.
+func SetEnvelopeForCode(httpCode int, e Envelope) {
+	setCodeTransformer(httpCode, transformerEntry{transform: e.Transformer, describer: e})
+}
+
+// setClassTransformer sets transformer for status class in copy of map of
+// class transformers, since maps of stored snapshot are not modified.
+func setClassTransformer(class StatusClass, t transformerEntry) {
+	update(func(s *settings) {
+		transformers := make(map[StatusClass]transformerEntry, len(s.classTransformers)+1)
+		for k, v := range s.classTransformers {
+			transformers[k] = v
+		}
+		transformers[class] = t
+		s.classTransformers = transformers
+	})
+}
+
+// setCodeTransformer is same as setClassTransformer, for status code.
+func setCodeTransformer(httpCode int, t transformerEntry) {
+	update(func(s *settings) {
+		transformers := make(map[int]transformerEntry, len(s.codeTransformers)+1)
+		for k, v := range s.codeTransformers {
+			transformers[k] = v
+		}
+		transformers[httpCode] = t
+		s.codeTransformers = transformers
+	})
+}
+
+// ResetTransformer resets current transformer to default one and removes
+// all transformers set for status classes and codes.
 func ResetTransformer() {
-	transformerLock.Lock()
-	defer transformerLock.Unlock()
-	transformer = defaultTransformer
+	update(func(s *settings) {
+		s.transformer = transformerEntry{transform: defaultEnvelope.Transformer, describer: defaultEnvelope}
+		s.classTransformers = map[StatusClass]transformerEntry{}
+		s.codeTransformers = map[int]transformerEntry{}
+	})
+}
+
+// TransformerFor returns transformer that is used for responses with provided
+// status code, which is transformer set for that code, for its class or global
+// one, in that order.
+func TransformerFor(httpCode int) RequestTransformer {
+	return transformerFor(httpCode).transform
+}
+
+// transformerFor returns most specific transformer for provided status code.
+func transformerFor(httpCode int) transformerEntry {
+	return current().transformerFor(httpCode)
+}
+
+func (s *settings) transformerFor(httpCode int) transformerEntry {
+	if t, ok := s.codeTransformers[httpCode]; ok {
+		return t
+	}
+	if t, ok := s.classTransformers[ClassOf(httpCode)]; ok {
+		return t
+	}
+	return s.transformer
 }
 
 // SetDefaultContentTypeHeader sets string that will be included in header
 // under Content-Type header. This will only be included if transformer function
 // does not already set Content-Type header.
 func SetDefaultContentTypeHeader(contentType string) {
-	contentTypeHeaderLock.Lock()
-	defer contentTypeHeaderLock.Unlock()
-	defaultContentTypeHeader = contentType
+	update(func(s *settings) {
+		s.contentType = contentType
+	})
 }
 
 // SetIndent sets flat that indicates that JSON response messages should
@@ -55,7 +244,158 @@ func SetDefaultContentTypeHeader(contentType string) {
 // debugging when consumer of JSON response is developer and not
 // other service.
 func SetIndent(flag bool) {
-	indentLock.Lock()
-	defer indentLock.Unlock()
-	indent = flag
+	update(func(s *settings) {
+		s.indent = flag
+	})
+}
+
+// SetKeyCasing sets casing of keys of JSON objects for fields of structs that
+// do not have name in json tag, e.g. SnakeCase writes field UserID as
+// "user_id". Names from json tags are always used as they are. Acronyms are
+// treated as single words, and they keep their case in CamelCase, e.g.
+// SetKeyCasing(CamelCase, "ID", "URL") writes field ImageURLs as "imageURLs".
+// Keys of maps are not changed. Responses that are already being written
+// keep casing with which they started.
+func SetKeyCasing(casing KeyCasing, acronyms ...string) {
+	update(func(s *settings) {
+		s.keyNaming = newKeyNaming(casing, acronyms)
+	})
+	clearCache(&fieldCache)
+	clearCache(&encoderCache)
+}
+
+func clearCache(cache *sync.Map) {
+	cache.Range(func(key, value interface{}) bool {
+		cache.Delete(key)
+		return true
+	})
+}
+
+// SetFieldsParameter sets name of query parameter that clients can use to
+// request only some fields of response data (e.g. ?fields=id,name,owner.email).
+// Fields are projected only for responses sent for request (e.g. via OKFor).
+// Default is "fields", empty string disables projection.
+func SetFieldsParameter(name string) {
+	update(func(s *settings) {
+		s.fieldsParam = name
+	})
+}
+
+// SetBufferLimit sets maximum size of response body in bytes that is
+// buffered before it is sent to client (which is needed in order to set
+// Content-Length header). Mode defines what happens with larger responses,
+// they can be replaced with response with status 500 (OversizeError), or
+// streamed to client without Content-Length (OversizeStream). When limit is
+// set, elements of objects and arrays are encoded one by one, so large response
+// is never encoded to memory at once. Canonical bodies and bodies signed with
+// JWS are encoded whole, and limit is applied to body that is sent. Headers of
+// response are not sent with status 500. Limit that is not positive (default)
+// means that there is no limit.
+func SetBufferLimit(limit int, mode OversizeMode) {
+	update(func(s *settings) {
+		s.bufferLimit = limit
+		s.oversizeMode = mode
+	})
+}
+
+// SetFastEncoder enables encoder that builds encoding plan for each type on
+// first use and caches it, instead of using encoding/json with its reflection
+// on each response. Redaction, views and projection of fields are applied while
+// encoding. Output is same as output of encoding/json. With buffer limit (see
+// SetBufferLimit), encoded elements of arrays and maps are written whenever
+// they exceed limit, except for indented responses, which are encoded element by
+// element by encoding/json.
+func SetFastEncoder(flag bool) {
+	update(func(s *settings) {
+		s.fastEncoder = flag
+	})
+}
+
+// SetHooks sets hooks invoked for every response, in provided order.
+// Calling it without arguments removes all hooks.
+func SetHooks(h ...Hooks) {
+	update(func(s *settings) {
+		s.hooks = h
+	})
+}
+
+// SetTimingsInMeta sets flag that indicates that server timing metrics (see
+// Timing and StartTimer) are included in "timings" field of "meta" object
+// of envelope, in addition to Server-Timing header. Metadata is included
+// only by default transformer and transformers that include Metadata field
+// of response.
+func SetTimingsInMeta(flag bool) {
+	update(func(s *settings) {
+		s.timingsInMeta = flag
+	})
+}
+
+// SetRequestIDHeader sets name of header from which RequestIDMiddleware reads
+// ID of request and in which it is sent back. Default is "X-Request-ID".
+func SetRequestIDHeader(name string) {
+	update(func(s *settings) {
+		s.requestIDHeader = name
+	})
+}
+
+// SetMetaField sets name of field of envelope that contains metadata of
+// response (see Meta). Default is "meta".
+func SetMetaField(name string) {
+	update(func(s *settings) {
+		s.metaField = name
+	})
+}
+
+// AddMetaProvider adds function that is called for every response and whose
+// result is added to "meta" object of envelope, e.g. server time, API version
+// or deprecation notice.
+func AddMetaProvider(p MetaProvider) {
+	update(func(s *settings) {
+		s.metaProviders = append(s.metaProviders[:len(s.metaProviders):len(s.metaProviders)], p)
+	})
+}
+
+// ResetMetaProviders removes all meta providers.
+func ResetMetaProviders() {
+	update(func(s *settings) {
+		s.metaProviders = nil
+	})
+}
+
+// SetNumberPolicy sets policy of encoding numbers in all responses, unless
+// response has its own (see Response.Numbers). Zero value of policy (default)
+// encodes numbers same as encoding/json.
+func SetNumberPolicy(p NumberPolicy) {
+	update(func(s *settings) {
+		s.numberPolicy = p
+	})
+}
+
+// SetSigning sets options of signing responses after they are encoded.
+// Content-Digest header (RFC 9530) is added to every buffered response, and
+// HTTP message signature (RFC 9421) if keys are provided. Responses that are
+// streamed because they exceed buffer limit (see SetBufferLimit) are not
+// signed. Zero value of options (default) disables signing.
+// Error is returned and options are not changed if digest algorithm is not
+// supported or keys can not sign, e.g. key is Ed25519PublicKey.
+func SetSigning(o SigningOptions) error {
+	if err := o.validate(); err != nil {
+		return err
+	}
+	update(func(s *settings) {
+		s.signing = o
+	})
+	return nil
+}
+
+// SetJWS sets options of JWS (RFC 7515) of all response bodies, unless
+// response has its own (see Response.JWS). Body is signed after it is encoded
+// and it is either replaced with compact JWS, or JWS with detached payload
+// is sent in x-jws-signature header. Responses that are streamed because
+// they exceed buffer limit (see SetBufferLimit) are not signed. Options
+// without key (default) disable JWS.
+func SetJWS(o JWSOptions) {
+	update(func(s *settings) {
+		s.jws = o
+	})
 }
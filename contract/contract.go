@@ -0,0 +1,199 @@
+// Package contract provides HTTP middleware that validates bodies of
+// responses against JSON Schema of route and status code, e.g. one
+// generated by jsonresponse.Schema or by openapi package. It is meant for
+// catching handlers that send responses of wrong shape.
+//
+// Example:
+//
+//	routes := contract.FromOpenAPI(openapi.Build())
+//	handler = contract.Middleware(contract.Options{
+//		Schemas:    routes.Lookup,
+//		SampleRate: 0.1,
+//	})(handler)
+package contract
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"log"
+	"math/rand"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/delicb/jsonresponse"
+)
+
+// Violation describes response whose body does not match its schema.
+type Violation struct {
+	Method string   `json:"method"`
+	Path   string   `json:"path"`
+	Status int      `json:"status"`
+	Errors []string `json:"errors"`
+}
+
+// Options configures validation middleware.
+type Options struct {
+	// Schemas returns schema for response with status code to request, or
+	// nil if response is not validated.
+	Schemas func(req *http.Request, status int) *Schema
+	// SampleRate is fraction of requests whose responses are validated,
+	// between 0 and 1. Zero value means that all responses are validated.
+	SampleRate float64
+	// Strict replaces response that does not match schema with
+	// 500 Internal Server Error describing the mismatch.
+	Strict bool
+	// OnViolation is called for each response that does not match schema.
+	// If not set, violations are logged with standard logger.
+	OnViolation func(req *http.Request, v Violation)
+}
+
+var violations int64
+
+// Violations returns number of responses that did not match schema since
+// start of program.
+func Violations() int64 {
+	return atomic.LoadInt64(&violations)
+}
+
+// Middleware returns middleware that validates responses of wrapped handler.
+// Responses of sampled requests are buffered until handler returns, so
+// streaming handlers should be excluded from validation.
+func Middleware(opts Options) func(http.Handler) http.Handler {
+	if opts.OnViolation == nil {
+		opts.OnViolation = logViolation
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if opts.Schemas == nil || !sampled(opts.SampleRate) {
+				next.ServeHTTP(w, req)
+				return
+			}
+			// headers set before handler, e.g. by other middleware, are kept
+			// in error response of strict mode
+			headers := w.Header().Clone()
+			rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, req)
+
+			if violation := validate(opts.Schemas, req, rec); violation != nil {
+				atomic.AddInt64(&violations, 1)
+				opts.OnViolation(req, *violation)
+				if opts.Strict {
+					for key := range w.Header() {
+						if _, ok := headers[key]; !ok {
+							delete(w.Header(), key)
+						}
+					}
+					for key, values := range headers {
+						w.Header()[key] = values
+					}
+					jsonresponse.New(violation).InternalServerError(w)
+					return
+				}
+			}
+			rec.flush()
+		})
+	}
+}
+
+func sampled(rate float64) bool {
+	return rate <= 0 || rate >= 1 || rand.Float64() < rate
+}
+
+func logViolation(req *http.Request, v Violation) {
+	log.Printf("contract: response %d to %s %s does not match schema: %s",
+		v.Status, v.Method, v.Path, strings.Join(v.Errors, "; "))
+}
+
+// validate returns violation if recorded response does not match its schema.
+// Responses without schema and responses that are not JSON are not validated.
+// Payload of response sent as compact JWS is validated, without verifying it.
+// Required fields are not checked in responses to requests that select fields
+// of data (see jsonresponse.RequestsFields).
+func validate(schemas func(*http.Request, int) *Schema, req *http.Request, rec *recorder) *Violation {
+	schema := schemas(req, rec.status)
+	if schema == nil {
+		return nil
+	}
+	var errs []string
+	body := rec.body.Bytes()
+	mediaType, _, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if mediaType == jsonresponse.JWSContentType {
+		payload, err := jwsPayload(body)
+		if err != nil {
+			errs = []string{"response is not valid JWS: " + err.Error()}
+		}
+		body, mediaType = payload, "application/json"
+	}
+	switch {
+	case len(errs) > 0:
+	case len(body) == 0:
+		errs = []string{"response has no body"}
+	case !isJSON(mediaType):
+		errs = []string{"response is not JSON, Content-Type is " + mediaType}
+	case jsonresponse.RequestsFields(req):
+		// data is projected to requested fields, so required fields may
+		// be missing
+		errs = schema.withoutRequired().Validate(body)
+	default:
+		errs = schema.Validate(body)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &Violation{Method: req.Method, Path: req.URL.Path, Status: rec.status, Errors: errs}
+}
+
+// jwsPayload returns payload of compact JWS.
+func jwsPayload(token []byte) ([]byte, error) {
+	parts := bytes.Split(bytes.TrimSpace(token), []byte("."))
+	if len(parts) != 3 {
+		return nil, errors.New("expected 3 parts of compact JWS")
+	}
+	payload := make([]byte, base64.RawURLEncoding.DecodedLen(len(parts[1])))
+	n, err := base64.RawURLEncoding.Decode(payload, parts[1])
+	return payload[:n], err
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// recorder buffers status code and body of response, headers are set
+// directly on wrapped writer.
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.body.Write(b)
+}
+
+// flush sends buffered response to wrapped writer.
+func (r *recorder) flush() {
+	r.ResponseWriter.WriteHeader(r.status)
+	r.ResponseWriter.Write(r.body.Bytes())
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,266 @@
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/delicb/jsonresponse"
+	"github.com/delicb/jsonresponse/openapi"
+)
+
+type user struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Manager *user  `json:"manager"`
+}
+
+func serve(handler http.Handler, opts Options, method, path string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	Middleware(opts)(handler).ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+	return recorder
+}
+
+func TestValidate(t *testing.T) {
+	schema := NewSchema(jsonresponse.Schema(user{}, nil))
+	for body, expected := range map[string]string{
+		`{"data":{"id":1,"name":"a","manager":null}}`:                               "",
+		`{"data":{"id":1,"name":"a","manager":{"id":2,"name":"b","manager":null}}}`: "",
+		`{"data":{"id":"1","name":"a","manager":null}}`:                             "$.data.id: expected integer, got string",
+		`{"data":{"id":1.5,"name":"a","manager":null}}`:                             "$.data.id: expected integer, got number",
+		`{"data":{"id":18446744073709551615,"name":"a","manager":null}}`:            "",
+		`{"data":{"id":-99999999999999999999999,"name":"a","manager":null}}`:        "",
+		`{"data":{"id":1e2,"name":"a","manager":null}}`:                             "$.data.id: expected integer, got number",
+		`{"data":{"id":1,"manager":null}}`:                                          `$.data: missing required field "name"`,
+		`{"data":{"id":1,"name":"a","manager":null,"age":3}}`:                       `$.data: unexpected field "age"`,
+		`{"data":{"id":1,"name":"a","manager":{"id":2}}}`:                           "$.data.manager: does not match any of allowed schemas",
+		`{}`:       `$: missing required field "data"`,
+		`not json`: "body is not valid JSON: invalid character 'o' in literal null (expecting 'u')",
+	} {
+		got := strings.Join(schema.Validate([]byte(body)), "; ")
+		if got != expected {
+			fmt.Printf("Body %s: expected %q, got %q\n", body, expected, got)
+			t.Fail()
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	routes := Routes{}.Add("GET /users/{id}", http.StatusOK, NewSchema(jsonresponse.Schema(user{}, nil)))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/users/1" {
+			jsonresponse.New(user{ID: 1, Name: "a"}).OK(w)
+			return
+		}
+		jsonresponse.New(map[string]string{"id": "2"}).OK(w)
+	})
+
+	var reported []Violation
+	opts := Options{
+		Schemas:     routes.Lookup,
+		OnViolation: func(r *http.Request, v Violation) { reported = append(reported, v) },
+	}
+	before := Violations()
+
+	valid := serve(handler, opts, "GET", "/users/1")
+	if valid.Code != http.StatusOK || len(reported) != 0 {
+		fmt.Printf("Expected valid response, got %d %s and violations %v\n", valid.Code, valid.Body.String(), reported)
+		t.Fail()
+	}
+
+	invalid := serve(handler, opts, "GET", "/users/2")
+	if invalid.Code != http.StatusOK || len(reported) != 1 || Violations() != before+1 {
+		fmt.Printf("Expected reported violation and unchanged response, got %d and violations %v\n", invalid.Code, reported)
+		t.Fail()
+	}
+	if v := reported[0]; v.Method != "GET" || v.Path != "/users/2" || v.Status != http.StatusOK || len(v.Errors) == 0 {
+		fmt.Printf("Unexpected violation %+v\n", v)
+		t.Fail()
+	}
+
+	unknown := serve(handler, opts, "POST", "/users/2")
+	if unknown.Code != http.StatusOK || len(reported) != 1 {
+		fmt.Printf("Expected response without schema not to be validated, got violations %v\n", reported)
+		t.Fail()
+	}
+}
+
+func TestMiddlewareWithProjectedFields(t *testing.T) {
+	routes := Routes{}.Add("GET /users/{id}", http.StatusOK, NewSchema(jsonresponse.Schema(user{}, nil)))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/users/1" {
+			jsonresponse.New(user{ID: 1, Name: "a"}).OKFor(w, r)
+			return
+		}
+		jsonresponse.New(map[string]string{"id": "2"}).OKFor(w, r)
+	})
+
+	var reported []Violation
+	opts := Options{
+		Schemas:     routes.Lookup,
+		Strict:      true,
+		OnViolation: func(r *http.Request, v Violation) { reported = append(reported, v) },
+	}
+	projected := serve(handler, opts, "GET", "/users/1?fields=id")
+	if projected.Code != http.StatusOK || projected.Body.String() != `{"data":{"id":1}}`+"\n" || len(reported) != 0 {
+		fmt.Printf("Expected projected response, got %d %s and violations %v\n", projected.Code, projected.Body.String(), reported)
+		t.Fail()
+	}
+
+	// types of fields are still validated
+	invalid := serve(handler, opts, "GET", "/users/2?fields=id")
+	if invalid.Code != http.StatusInternalServerError || len(reported) != 1 ||
+		strings.Join(reported[0].Errors, "; ") != "$.data.id: expected integer, got string" {
+		fmt.Printf("Expected violation of projected response, got %d and violations %v\n", invalid.Code, reported)
+		t.Fail()
+	}
+}
+
+func TestMiddlewareStrict(t *testing.T) {
+	routes := Routes{}.Add("GET /users", http.StatusOK, NewSchema(jsonresponse.Schema([]user{}, nil)))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jsonresponse.New("not users").Header("Link", `</users?page=2>; rel="next"`).Header("Cache-Control", "max-age=60").OK(w)
+	})
+
+	recorder := httptest.NewRecorder()
+	recorder.Header().Set("Vary", "Origin")
+	Middleware(Options{
+		Schemas:     routes.Lookup,
+		Strict:      true,
+		OnViolation: func(*http.Request, Violation) {},
+	})(handler).ServeHTTP(recorder, httptest.NewRequest("GET", "/users", nil))
+	if recorder.Header().Get("Link") != "" || recorder.Header().Get("Cache-Control") != "" || recorder.Header().Get("Vary") != "Origin" {
+		fmt.Printf("Expected only headers set before handler, got %v\n", recorder.Header())
+		t.Fail()
+	}
+	var body struct {
+		Data Violation `json:"data"`
+	}
+	json.Unmarshal(recorder.Body.Bytes(), &body)
+	if recorder.Code != http.StatusInternalServerError || body.Data.Path != "/users" ||
+		strings.Join(body.Data.Errors, "; ") != "$.data: expected array or null, got string" {
+		fmt.Printf("Expected 500 describing mismatch, got %d %s\n", recorder.Code, recorder.Body.String())
+		t.Fail()
+	}
+}
+
+type account struct {
+	ID       int64             `json:"id"`
+	Balance  float64           `json:"balance"`
+	Password string            `json:"password"`
+	Extra    map[string]string `json:"extra"`
+}
+
+func TestMiddlewareStrictAcceptsEncodedResponses(t *testing.T) {
+	jsonresponse.SetNumberPolicy(jsonresponse.NumberPolicy{BigIntsAsStrings: true, NonFinite: jsonresponse.NonFiniteNull})
+	defer jsonresponse.SetNumberPolicy(jsonresponse.NumberPolicy{})
+	jsonresponse.SetRedactedKeys("password")
+	defer jsonresponse.SetRedactedKeys()
+
+	routes := Routes{}.Add("GET /account", http.StatusOK, NewSchema(jsonresponse.Schema(account{}, nil)))
+	for _, jws := range []bool{false, true} {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			response := jsonresponse.New(account{
+				ID:       1 << 60,
+				Balance:  math.Inf(1),
+				Password: "secret",
+				Extra:    map[string]string{"password": "secret"},
+			})
+			if jws {
+				response = response.JWS(jsonresponse.JWSOptions{Key: jsonresponse.HS256Key([]byte("secret"))})
+			}
+			response.OK(w)
+		})
+		var reported []Violation
+		recorder := serve(handler, Options{
+			Schemas:     routes.Lookup,
+			Strict:      true,
+			OnViolation: func(r *http.Request, v Violation) { reported = append(reported, v) },
+		}, "GET", "/account")
+		if recorder.Code != http.StatusOK || len(reported) != 0 {
+			fmt.Printf("JWS %v: expected valid response, got %d %s and violations %v\n", jws, recorder.Code, recorder.Body.String(), reported)
+			t.Fail()
+		}
+	}
+}
+
+func TestRoutesLookupPrecedence(t *testing.T) {
+	me, byID, posts, all := &Schema{}, &Schema{}, &Schema{}, &Schema{}
+	routes := Routes{}.
+		Add("GET /users/{id}", http.StatusOK, byID).
+		Add("GET /users/me", http.StatusOK, me).
+		Add("GET /users/{id}/posts", http.StatusOK, posts).
+		Add("GET /{collection}/{id}/posts", http.StatusOK, all).
+		Add("GET /users/{name}", http.StatusNotFound, byID)
+	for i := 0; i < 20; i++ {
+		for path, expected := range map[string]*Schema{
+			"/users/me":       me,
+			"/users/1":        byID,
+			"/users/1/posts":  posts,
+			"/groups/1/posts": all,
+		} {
+			if schema := routes.Lookup(httptest.NewRequest("GET", path, nil), http.StatusOK); schema != expected {
+				fmt.Printf("Unexpected schema for %s\n", path)
+				t.Fail()
+			}
+		}
+	}
+}
+
+func TestMiddlewareSampling(t *testing.T) {
+	called := false
+	schemas := func(*http.Request, int) *Schema {
+		called = true
+		return nil
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	for i := 0; i < 100; i++ {
+		serve(handler, Options{Schemas: schemas, SampleRate: 0.0000001}, "GET", "/")
+	}
+	if called {
+		fmt.Println("Expected responses not to be sampled")
+		t.Fail()
+	}
+}
+
+func TestFromOpenAPI(t *testing.T) {
+	openapi.Register(openapi.Operation{
+		Method:    "GET",
+		Path:      "/users/{id}",
+		Responses: map[int]interface{}{http.StatusOK: user{}},
+		Errors:    map[int]interface{}{http.StatusNotFound: nil},
+	}, nil)
+	defer openapi.Reset()
+	routes := FromOpenAPI(openapi.Build())
+
+	for _, c := range []struct {
+		method, path string
+		status       int
+		body         string
+		errors       int
+	}{
+		{"GET", "/users/1", http.StatusOK, `{"data":{"id":1,"name":"a","manager":null}}`, 0},
+		{"GET", "/users/1", http.StatusOK, `{"data":{"id":1,"name":"a","manager":{"id":2}}}`, 1},
+		{"GET", "/users/1", http.StatusNotFound, `{"message":"Not Found"}`, 0},
+		{"GET", "/users/1", http.StatusNotFound, `{"error":"Not Found"}`, 1},
+	} {
+		schema := routes.Lookup(httptest.NewRequest(c.method, c.path, nil), c.status)
+		if schema == nil {
+			fmt.Printf("Expected schema for %s %s %d\n", c.method, c.path, c.status)
+			t.Fail()
+			continue
+		}
+		if errs := schema.Validate([]byte(c.body)); len(errs) != c.errors {
+			fmt.Printf("Body %s: expected %d errors, got %v\n", c.body, c.errors, errs)
+			t.Fail()
+		}
+	}
+	if routes.Lookup(httptest.NewRequest("GET", "/users", nil), http.StatusOK) != nil {
+		fmt.Println("Expected no schema for unknown route")
+		t.Fail()
+	}
+}
@@ -0,0 +1,122 @@
+package contract
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/delicb/jsonresponse/openapi"
+)
+
+// Routes contains schemas of responses for each route and status code.
+// Route is method and path, parameters of path are in braces, e.g.
+// "GET /users/{id}".
+type Routes map[string]map[int]*Schema
+
+// Add sets schema of response with status code to route.
+func (r Routes) Add(route string, status int, schema *Schema) Routes {
+	if r[route] == nil {
+		r[route] = map[int]*Schema{}
+	}
+	r[route][status] = schema
+	return r
+}
+
+// Lookup returns schema of response with status code to request, or nil
+// if there is none. It can be used as Options.Schemas. If multiple routes
+// match request, route whose first differing segment is literal is used,
+// e.g. "GET /users/me" before "GET /users/{id}".
+func (r Routes) Lookup(req *http.Request, status int) *Schema {
+	var matched []string
+	for route, schemas := range r {
+		parts := strings.SplitN(route, " ", 2)
+		if len(parts) != 2 || !strings.EqualFold(parts[0], req.Method) || !matchPath(parts[1], req.URL.Path) {
+			continue
+		}
+		if _, ok := schemas[status]; ok {
+			matched = append(matched, route)
+		}
+	}
+	if len(matched) == 0 {
+		return nil
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return precedes(matched[i], matched[j])
+	})
+	return r[matched[0]][status]
+}
+
+// precedes returns true if route a is more specific than route b, both
+// matching same path. Routes equally specific are ordered as strings.
+func precedes(a, b string) bool {
+	aSegments := strings.Split(a, "/")
+	bSegments := strings.Split(b, "/")
+	for i := 0; i < len(aSegments) && i < len(bSegments); i++ {
+		aParameter, bParameter := isParameter(aSegments[i]), isParameter(bSegments[i])
+		if aParameter != bParameter {
+			return bParameter
+		}
+	}
+	return a < b
+}
+
+func isParameter(segment string) bool {
+	return strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+// matchPath returns true if path matches template, where segments in braces
+// match any segment.
+func matchPath(template, path string) bool {
+	templateSegments := strings.Split(strings.Trim(template, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(templateSegments) != len(pathSegments) {
+		return false
+	}
+	for i, segment := range templateSegments {
+		if isParameter(segment) {
+			continue
+		}
+		if segment != pathSegments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// FromOpenAPI returns schemas of JSON responses described in document.
+// References to components are resolved in document.
+func FromOpenAPI(doc *openapi.Document) Routes {
+	root, _ := normalize(doc).(map[string]interface{})
+	paths, _ := root["paths"].(map[string]interface{})
+	routes := Routes{}
+	for path, item := range paths {
+		methods, _ := item.(map[string]interface{})
+		for method, op := range methods {
+			responses, _ := lookup(op, "responses").(map[string]interface{})
+			for code, response := range responses {
+				status, err := strconv.Atoi(code)
+				if err != nil {
+					continue
+				}
+				schema := lookup(response, "content", "application/json", "schema")
+				if schema == nil {
+					continue
+				}
+				routes.Add(strings.ToUpper(method)+" "+path, status, &Schema{schema: schema, root: root})
+			}
+		}
+	}
+	return routes
+}
+
+func lookup(v interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
@@ -0,0 +1,264 @@
+package contract
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Schema is JSON Schema of response body, together with document in which
+// its references ($ref) are resolved.
+type Schema struct {
+	schema interface{}
+	root   interface{}
+	// partial is set for bodies in which fields may be omitted, so required
+	// fields are not checked.
+	partial bool
+}
+
+// NewSchema creates schema from JSON Schema, e.g. one returned by
+// jsonresponse.Schema. References are resolved in schema itself.
+func NewSchema(schema map[string]interface{}) *Schema {
+	normalized := normalize(schema)
+	return &Schema{schema: normalized, root: normalized}
+}
+
+// normalize converts value to same representation that decoding of JSON
+// produces, so schemas built in code and decoded ones are same.
+func normalize(v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	var normalized interface{}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	decoder.Decode(&normalized)
+	return normalized
+}
+
+// Validate returns list of differences between body and schema, which is
+// empty if body is valid.
+func (s *Schema) Validate(body []byte) []string {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return []string{"body is not valid JSON: " + err.Error()}
+	}
+	return s.validate(s.schema, value, "$", nil, 0)
+}
+
+// withoutRequired returns same schema that does not check presence of
+// required fields, e.g. for responses with projected fields.
+func (s *Schema) withoutRequired() *Schema {
+	return &Schema{schema: s.schema, root: s.root, partial: true}
+}
+
+// maxRefDepth limits how many references are followed, which protects
+// against schemas that refer to themselves without consuming value.
+const maxRefDepth = 64
+
+func (s *Schema) validate(schema, value interface{}, path string, errs []string, refs int) []string {
+	rules, ok := schema.(map[string]interface{})
+	if !ok {
+		// true or missing schema allows anything, false allows nothing
+		if schema == false {
+			return append(errs, path+": is not allowed")
+		}
+		return errs
+	}
+
+	if ref, ok := rules["$ref"].(string); ok {
+		if refs > maxRefDepth {
+			return append(errs, path+": too many references")
+		}
+		target, err := s.resolve(ref)
+		if err != nil {
+			return append(errs, path+": "+err.Error())
+		}
+		errs = s.validate(target, value, path, errs, refs+1)
+	}
+
+	if t, ok := rules["type"]; ok && !matchesType(t, value) {
+		return append(errs, fmt.Sprintf("%s: expected %s, got %s", path, typeName(t), kindOf(value)))
+	}
+	if c, ok := rules["const"]; ok && !reflect.DeepEqual(c, value) {
+		errs = append(errs, fmt.Sprintf("%s: expected %v, got %v", path, c, value))
+	}
+	if enum, ok := rules["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			found = found || reflect.DeepEqual(e, value)
+		}
+		if !found {
+			errs = append(errs, fmt.Sprintf("%s: %v is not one of %v", path, value, enum))
+		}
+	}
+	if all, ok := rules["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			errs = s.validate(sub, value, path, errs, refs)
+		}
+	}
+	if any, ok := rules["anyOf"].([]interface{}); ok {
+		matched := 0
+		for _, sub := range any {
+			if len(s.validate(sub, value, path, nil, refs)) == 0 {
+				matched++
+			}
+		}
+		if matched == 0 {
+			errs = append(errs, path+": does not match any of allowed schemas")
+		}
+	}
+	if one, ok := rules["oneOf"].([]interface{}); ok {
+		matched := 0
+		for _, sub := range one {
+			if len(s.validate(sub, value, path, nil, refs)) == 0 {
+				matched++
+			}
+		}
+		if matched != 1 {
+			errs = append(errs, fmt.Sprintf("%s: matches %d schemas instead of one", path, matched))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		errs = s.validateObject(rules, v, path, errs, refs)
+	case []interface{}:
+		errs = s.validateArray(rules, v, path, errs, refs)
+	}
+	return errs
+}
+
+func (s *Schema) validateObject(rules map[string]interface{}, value map[string]interface{}, path string, errs []string, refs int) []string {
+	properties, _ := rules["properties"].(map[string]interface{})
+	if required, ok := rules["required"].([]interface{}); ok && !s.partial {
+		for _, name := range required {
+			if _, ok := value[fmt.Sprint(name)]; !ok {
+				errs = append(errs, fmt.Sprintf("%s: missing required field %q", path, name))
+			}
+		}
+	}
+	for _, name := range sortedKeys(value) {
+		child := path + "." + name
+		if schema, ok := properties[name]; ok {
+			errs = s.validate(schema, value[name], child, errs, refs)
+			continue
+		}
+		if additional, ok := rules["additionalProperties"]; ok {
+			if additional == false {
+				errs = append(errs, fmt.Sprintf("%s: unexpected field %q", path, name))
+				continue
+			}
+			errs = s.validate(additional, value[name], child, errs, refs)
+		}
+	}
+	return errs
+}
+
+func (s *Schema) validateArray(rules map[string]interface{}, value []interface{}, path string, errs []string, refs int) []string {
+	if min, ok := rules["minItems"].(json.Number); ok {
+		if n, _ := min.Int64(); int64(len(value)) < n {
+			errs = append(errs, fmt.Sprintf("%s: expected at least %d items, got %d", path, n, len(value)))
+		}
+	}
+	if max, ok := rules["maxItems"].(json.Number); ok {
+		if n, _ := max.Int64(); int64(len(value)) > n {
+			errs = append(errs, fmt.Sprintf("%s: expected at most %d items, got %d", path, n, len(value)))
+		}
+	}
+	if items, ok := rules["items"]; ok {
+		for i, item := range value {
+			errs = s.validate(items, item, path+"["+strconv.Itoa(i)+"]", errs, refs)
+		}
+	}
+	return errs
+}
+
+// resolve returns schema referenced by JSON pointer in root document,
+// e.g. "#/$defs/User".
+func (s *Schema) resolve(ref string) (interface{}, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("only local references are supported, got %s", ref)
+	}
+	target := s.root
+	for _, token := range strings.Split(strings.TrimPrefix(ref, "#"), "/")[1:] {
+		token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+		obj, ok := target.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("reference %s not found", ref)
+		}
+		if target, ok = obj[token]; !ok {
+			return nil, fmt.Errorf("reference %s not found", ref)
+		}
+	}
+	return target, nil
+}
+
+func matchesType(t interface{}, value interface{}) bool {
+	switch types := t.(type) {
+	case string:
+		return matchesTypeName(types, value)
+	case []interface{}:
+		for _, name := range types {
+			if matchesTypeName(fmt.Sprint(name), value) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func matchesTypeName(name string, value interface{}) bool {
+	switch name {
+	case "integer":
+		// integer is decided from literal, so it is not limited by range
+		// of int64 or precision of float64
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		_, ok = new(big.Int).SetString(n.String(), 10)
+		return ok
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	}
+	return kindOf(value) == name
+}
+
+func typeName(t interface{}) string {
+	if types, ok := t.([]interface{}); ok {
+		names := make([]string, len(types))
+		for i, name := range types {
+			names[i] = fmt.Sprint(name)
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+func kindOf(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", value)
+}
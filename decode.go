@@ -0,0 +1,172 @@
+package jsonresponse
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// APIError is returned by DecodeResponse for responses with error status.
+type APIError struct {
+	// Status is HTTP status code of response.
+	Status int
+	// Code is code from response body, or status code if body has none.
+	Code int
+	// Message from response body, or status text if body has none.
+	Message string
+	// Problem is set if response is in Problem Details format.
+	Problem *Problem
+	// Body is raw body of response.
+	Body []byte
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("jsonresponse: %d %s: %s", e.Status, http.StatusText(e.Status), e.Message)
+}
+
+// DecodeOption configures DecodeResponse.
+type DecodeOption func(o *decodeOptions)
+
+type decodeOptions struct {
+	dataField string
+	codeField string
+	verify    bool
+	keys      KeyProvider
+	// verifyOptions are passed to VerifyResponse
+	verifyOptions []VerifyOption
+}
+
+// WithDataField sets name of field in which data of response is wrapped,
+// which is "data" by default (see MessageCodeTransformer). Empty name means
+// that data is not wrapped.
+func WithDataField(name string) DecodeOption {
+	return func(o *decodeOptions) {
+		o.dataField = name
+	}
+}
+
+// WithCodeField sets name of field with status code in responses (see
+// MessageCodeTransformer), it is used as code of APIError.
+func WithCodeField(name string) DecodeOption {
+	return func(o *decodeOptions) {
+		o.codeField = name
+	}
+}
+
+// WithVerification verifies Content-Digest header of response against its
+// body, and HTTP message signature of response if keys are provided (see
+// VerifyResponse), before response is decoded.
+func WithVerification(keys KeyProvider, opts ...VerifyOption) DecodeOption {
+	return func(o *decodeOptions) {
+		o.verify = true
+		o.keys = keys
+		o.verifyOptions = opts
+	}
+}
+
+// DecodeResponse reads and closes body of response sent by other service that
+// uses this package, and decodes its data to T. Data is unwrapped from envelope,
+// if body does not contain data field, whole body is decoded.
+//
+// For responses with error status (4xx and 5xx) *APIError is returned. It
+// understands envelopes, MessageResponse and Problem Details.
+func DecodeResponse[T interface{}](resp *http.Response, opts ...DecodeOption) (T, error) {
+	var result T
+	o := decodeOptions{dataField: "data"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return result, err
+	}
+	if o.verify {
+		if err := VerifyResponse(resp, body, o.keys, o.verifyOptions...); err != nil {
+			return result, err
+		}
+	}
+	if resp.StatusCode >= 400 {
+		return result, newAPIError(resp, body, o)
+	}
+	if len(body) == 0 {
+		return result, nil
+	}
+
+	data := json.RawMessage(body)
+	if o.dataField != "" {
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(body, &fields) == nil {
+			if d, ok := fields[o.dataField]; ok {
+				data = d
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// newAPIError creates error from body of error response.
+func newAPIError(resp *http.Response, body []byte, o decodeOptions) *APIError {
+	apiErr := &APIError{
+		Status:  resp.StatusCode,
+		Code:    resp.StatusCode,
+		Message: http.StatusText(resp.StatusCode),
+		Body:    body,
+	}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) != nil {
+		return apiErr
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	_, hasTitle := fields["title"]
+	if mediaType == ProblemContentType || hasTitle && (fields["type"] != nil || fields["status"] != nil) {
+		problem := &Problem{}
+		if json.Unmarshal(body, problem) == nil {
+			apiErr.Problem = problem
+			if problem.Status != 0 {
+				apiErr.Code = problem.Status
+			}
+			if problem.Detail != "" {
+				apiErr.Message = problem.Detail
+			} else if problem.Title != "" {
+				apiErr.Message = problem.Title
+			}
+			return apiErr
+		}
+	}
+
+	if raw, ok := fields[o.codeField]; ok && o.codeField != "" {
+		json.Unmarshal(raw, &apiErr.Code)
+	}
+	// message can be in data of envelope, as string or MessageResponse
+	if raw, ok := fields[o.dataField]; ok && o.dataField != "" {
+		var message string
+		if json.Unmarshal(raw, &message) == nil {
+			if message != "" {
+				apiErr.Message = message
+			}
+			return apiErr
+		}
+		fields = nil
+		json.Unmarshal(raw, &fields)
+	}
+	var message MessageResponse
+	if raw, ok := fields["code"]; ok {
+		if json.Unmarshal(raw, &message.Code) == nil && message.Code != 0 {
+			apiErr.Code = message.Code
+		}
+	}
+	if raw, ok := fields["message"]; ok {
+		if json.Unmarshal(raw, &message.Message) == nil && message.Message != "" {
+			apiErr.Message = message.Message
+		}
+	}
+	return apiErr
+}
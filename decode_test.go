@@ -0,0 +1,113 @@
+package jsonresponse
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type decodedUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func record(handler func(w http.ResponseWriter)) *http.Response {
+	recorder := httptest.NewRecorder()
+	handler(recorder)
+	return recorder.Result()
+}
+
+func TestDecodeResponse(t *testing.T) {
+	user := decodedUser{ID: 1, Name: "foo"}
+
+	resp := record(func(w http.ResponseWriter) { New(user).OK(w) })
+	if got, err := DecodeResponse[decodedUser](resp); err != nil || got != user {
+		fmt.Printf("Expected %v from default envelope, got %v (%v)\n", user, got, err)
+		t.Fail()
+	}
+
+	SetEnvelope(MessageCodeTransformer("payload", "status"))
+	resp = record(func(w http.ResponseWriter) { New(user).Created(w) })
+	ResetTransformer()
+	if got, err := DecodeResponse[decodedUser](resp, WithDataField("payload"), WithCodeField("status")); err != nil || got != user {
+		fmt.Printf("Expected %v from custom envelope, got %v (%v)\n", user, got, err)
+		t.Fail()
+	}
+
+	resp = record(func(w http.ResponseWriter) { OK(w, user) })
+	if got, err := DecodeResponse[*decodedUser](resp); err != nil || *got != user {
+		fmt.Printf("Expected %v without envelope, got %v (%v)\n", user, got, err)
+		t.Fail()
+	}
+
+	resp = record(func(w http.ResponseWriter) { New(nil).NoContent(w) })
+	if _, err := DecodeResponse[decodedUser](resp); err != nil {
+		fmt.Printf("Unexpected error for empty body: %v\n", err)
+		t.Fail()
+	}
+}
+
+func TestDecodeResponseErrors(t *testing.T) {
+	for name, c := range map[string]struct {
+		resp    *http.Response
+		opts    []DecodeOption
+		code    int
+		message string
+		problem bool
+	}{
+		"message response": {
+			resp:    record(func(w http.ResponseWriter) { NotFound(w, nil) }),
+			code:    404,
+			message: "Not Found",
+		},
+		"message in envelope": {
+			resp:    record(func(w http.ResponseWriter) { New("user is disabled").Forbidden(w) }),
+			code:    403,
+			message: "user is disabled",
+		},
+		"message response in envelope": {
+			resp:    record(func(w http.ResponseWriter) { New(MessageResponse{Code: 1001, Message: "invalid name"}).BadRequest(w) }),
+			code:    1001,
+			message: "invalid name",
+		},
+		"code field": {
+			resp: record(func(w http.ResponseWriter) {
+				SetEnvelope(MessageCodeTransformer("payload", "status"))
+				defer ResetTransformer()
+				New("conflict").Conflict(w)
+			}),
+			opts:    []DecodeOption{WithDataField("payload"), WithCodeField("status")},
+			code:    409,
+			message: "conflict",
+		},
+		"problem": {
+			resp: record(func(w http.ResponseWriter) {
+				w.Header().Set("Content-Type", ProblemContentType)
+				Respond(w, 422, Problem{Type: "/errors/validation", Title: "Validation failed", Status: 422, Detail: "name is required"})
+			}),
+			code:    422,
+			message: "name is required",
+			problem: true,
+		},
+		"not json": {
+			resp: record(func(w http.ResponseWriter) {
+				http.Error(w, "upstream failed", http.StatusBadGateway)
+			}),
+			code:    502,
+			message: "Bad Gateway",
+		},
+	} {
+		_, err := DecodeResponse[decodedUser](c.resp, c.opts...)
+		apiErr, ok := err.(*APIError)
+		if !ok {
+			fmt.Printf("%s: expected *APIError, got %v\n", name, err)
+			t.Fail()
+			continue
+		}
+		if apiErr.Code != c.code || apiErr.Message != c.message || (apiErr.Problem != nil) != c.problem || len(apiErr.Body) == 0 {
+			fmt.Printf("%s: unexpected error %+v\n", name, apiErr)
+			t.Fail()
+		}
+	}
+}
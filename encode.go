@@ -0,0 +1,460 @@
+package jsonresponse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxPooledBufferSize is capacity above which buffers are not returned to
+// pool, so single large response does not keep memory allocated forever.
+const maxPooledBufferSize = 1 << 20
+
+// errResponseTooLarge is returned while encoding when response exceeds
+// buffer limit and OversizeError mode is used.
+var errResponseTooLarge = errors.New("jsonresponse: response exceeds buffer limit")
+
+// OversizeMode defines what happens when response exceeds buffer limit.
+type OversizeMode int
+
+const (
+	// OversizeError sends response with status 500 instead of response that
+	// exceeds limit.
+	OversizeError OversizeMode = iota
+	// OversizeStream stops buffering and streams response to client without
+	// Content-Length header.
+	OversizeStream
+)
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// writeBody sets headers (and default Content-Type), encodes body and writes it to writer with status
+// code. Body is buffered, so Content-Length can be set, unless it exceeds
+// buffer limit (see SetBufferLimit). Hooks (see SetHooks) are invoked with
+// provided context and data of response. Body is written with provided
+// settings, which are snapshot of configuration for response.
+func writeBody(ctx context.Context, w http.ResponseWriter, httpCode int, data, body interface{}, headers map[string]string, s *settings) {
+	hooks := s.hooks
+	if len(hooks) == 0 {
+		if err := writeBodyWithLimit(w, httpCode, body, headers, s); err != nil && !replaced(err) {
+			panic(err)
+		}
+		return
+	}
+
+	for _, h := range hooks {
+		h.BeforeEncode(ctx, httpCode, data)
+	}
+	start := time.Now()
+	cw := &countingWriter{ResponseWriter: w, status: httpCode}
+	err := writeBodyWithLimit(cw, httpCode, body, headers, s)
+	duration := time.Since(start)
+	for _, h := range hooks {
+		h.AfterWrite(ctx, cw.status, cw.written, duration, err)
+	}
+	if err != nil && !replaced(err) {
+		panic(err)
+	}
+}
+
+// writeBodyWithLimit is same as writeBody, without hooks. Buffer limit and
+// oversize mode are taken from settings, limit that is not positive means that
+// there is no limit. Error is returned if body can not be encoded, if it is
+// errResponseTooLarge or signingError, response with status 500 is already
+// sent instead.
+func writeBodyWithLimit(w http.ResponseWriter, httpCode int, body interface{}, headers map[string]string, s *settings) error {
+	responseHeaders := w.Header()
+	for k, v := range headers {
+		responseHeaders.Set(k, v)
+	}
+	// if Content-Type is not already included - add it here
+	if _, ok := headers["Content-Type"]; !ok && s.contentType != "" {
+		responseHeaders.Set("Content-Type", s.contentType)
+	}
+	if body == nil {
+		w.WriteHeader(httpCode)
+		return nil
+	}
+
+	limit, mode, indent := s.bufferLimit, s.oversizeMode, s.indent
+	jwsOptions := s.jws
+	if j, ok := body.(jwsBody); ok {
+		jwsOptions = j.options
+		body = j.data
+	}
+	c, isCanonical := body.(canonical)
+	if isCanonical {
+		body = c.data
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	streaming := false
+	lw := &limitedWriter{buf: buf, limit: limit, overflow: func() (io.Writer, error) {
+		if mode == OversizeError {
+			return nil, errResponseTooLarge
+		}
+		streaming = true
+		w.WriteHeader(httpCode)
+		return w, nil
+	}}
+	// canonical and signed bodies can be produced only from whole encoded
+	// body, so limit is applied to body that is sent instead
+	if isCanonical || jwsOptions.Key != nil {
+		lw.limit = 0
+	}
+
+	var err error
+	switch {
+	case isCanonical:
+		err = encodeCanonical(lw, body, indent, s)
+	case s.fastEncoder && (lw.limit <= 0 || !indent):
+		err = encodeFastWithLimit(lw, body, indent, lw.limit, s)
+	case lw.limit > 0:
+		// encoding element by element is slower, so it is used only when
+		// it is needed to prevent large response from being encoded at once
+		err = encodeStream(lw, body, indent, s)
+	default:
+		err = encode(lw, body, indent, s)
+	}
+	if err != nil {
+		if err == errResponseTooLarge {
+			replaceWithError(w, headers)
+		}
+		return err
+	}
+	if streaming {
+		return nil
+	}
+
+	out := buf.Bytes()
+	if jwsOptions.Key != nil {
+		token, err := signJWS(out, jwsOptions, jwsOptions.Detached)
+		if err != nil {
+			replaceWithUnsignedError(w, headers)
+			return &signingError{err}
+		}
+		if jwsOptions.Detached {
+			responseHeaders.Set(JWSHeader, token)
+		} else {
+			out = []byte(token)
+			responseHeaders.Set("Content-Type", JWSContentType)
+		}
+	}
+	if limit > 0 && len(out) > limit {
+		if mode == OversizeError {
+			replaceWithError(w, headers)
+			return errResponseTooLarge
+		}
+		w.WriteHeader(httpCode)
+		w.Write(out)
+		return nil
+	}
+	if err := signBody(responseHeaders, httpCode, out, s.signing); err != nil {
+		replaceWithUnsignedError(w, headers)
+		return &signingError{err}
+	}
+	if responseHeaders.Get("Content-Length") == "" && bodyAllowed(httpCode) {
+		responseHeaders.Set("Content-Length", strconv.Itoa(len(out)))
+	}
+	w.WriteHeader(httpCode)
+	w.Write(out)
+	return nil
+}
+
+// replaceWithError sends response with status 500 instead of response that
+// can not be sent. Headers of response are removed first, so they are not
+// sent with error.
+func replaceWithError(w http.ResponseWriter, headers map[string]string) {
+	removeHeaders(w, headers)
+	respond(context.Background(), w, http.StatusInternalServerError, nil, false)
+}
+
+// replaceWithUnsignedError is same as replaceWithError, except that error is
+// neither signed nor sent as JWS, since signing of response failed.
+func replaceWithUnsignedError(w http.ResponseWriter, headers map[string]string) {
+	removeHeaders(w, headers)
+	s := *current()
+	s.signing, s.jws = SigningOptions{}, JWSOptions{}
+	respondWith(context.Background(), w, http.StatusInternalServerError, nil, false, &s)
+}
+
+func removeHeaders(w http.ResponseWriter, headers map[string]string) {
+	responseHeaders := w.Header()
+	for k := range headers {
+		responseHeaders.Del(k)
+	}
+	for _, k := range []string{"Content-Type", "Content-Length", "Content-Digest", "Signature-Input", "Signature", JWSHeader} {
+		responseHeaders.Del(k)
+	}
+}
+
+// signingError is returned by writeBodyWithLimit if response could not be
+// signed, in which case unsigned response with status 500 is sent instead.
+type signingError struct {
+	err error
+}
+
+func (e *signingError) Error() string {
+	return "jsonresponse: signing response: " + e.err.Error()
+}
+
+func (e *signingError) Unwrap() error {
+	return e.err
+}
+
+// replaced returns true if err means that response with status 500 was sent
+// instead of response that could not be sent.
+func replaced(err error) bool {
+	_, ok := err.(*signingError)
+	return ok || err == errResponseTooLarge
+}
+
+// countingWriter records status code and number of bytes of response
+// written through it.
+type countingWriter struct {
+	http.ResponseWriter
+	status      int
+	written     int
+	wroteHeader bool
+}
+
+func (cw *countingWriter) WriteHeader(status int) {
+	if !cw.wroteHeader {
+		cw.status = status
+		cw.wroteHeader = true
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *countingWriter) Write(b []byte) (int, error) {
+	cw.wroteHeader = true
+	n, err := cw.ResponseWriter.Write(b)
+	cw.written += n
+	return n, err
+}
+
+// limitedWriter buffers everything written to it up to limit. When limit
+// is exceeded, overflow is called to get writer to which buffered data and
+// everything after it is written, or to abort encoding with error.
+type limitedWriter struct {
+	buf      *bytes.Buffer
+	limit    int
+	overflow func() (io.Writer, error)
+	out      io.Writer
+}
+
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	if lw.out != nil {
+		return lw.out.Write(p)
+	}
+	if lw.limit <= 0 || lw.buf.Len()+len(p) <= lw.limit {
+		return lw.buf.Write(p)
+	}
+	out, err := lw.overflow()
+	if err != nil {
+		return 0, err
+	}
+	lw.out = out
+	if _, err := out.Write(lw.buf.Bytes()); err != nil {
+		return 0, err
+	}
+	lw.buf.Reset()
+	return out.Write(p)
+}
+
+// encode writes JSON encoding of data, followed by newline, to writer. Data
+// is converted with provided snapshot of configuration.
+func encode(w io.Writer, data interface{}, indent bool, s *settings) error {
+	// tagged sensitive fields are redacted regardless of transformer that produced data,
+	// and fields that are visible only in some views are removed
+	if needsConversion(data, s) {
+		data = toTree(data, s)
+	}
+	enc := json.NewEncoder(w)
+	if indent {
+		enc.SetIndent("", "\t")
+	}
+	return enc.Encode(data)
+}
+
+// encodeStream is same as encode, but elements of objects and arrays are
+// encoded and written one by one, so data is never encoded to memory all
+// at once.
+func encodeStream(w io.Writer, data interface{}, indent bool, s *settings) error {
+	if needsConversion(data, s) {
+		data = toTree(data, s)
+	}
+	e := &streamEncoder{w: w, indent: indent}
+	if err := e.encode(data, 0); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// streamEncoder writes containers element by element and leaves everything
+// else to encoding/json.
+type streamEncoder struct {
+	w       io.Writer
+	indent  bool
+	scratch bytes.Buffer
+}
+
+func (e *streamEncoder) encode(v interface{}, depth int) error {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return e.encodeObject(keys, func(k string) interface{} { return t[k] }, depth)
+	case *object:
+		return e.encodeObject(t.keys, func(k string) interface{} { return t.values[k] }, depth)
+	case *filtered:
+		return e.encode(t.tree(), depth)
+	case []interface{}:
+		return e.encodeArray(len(t), func(i int) interface{} { return t[i] }, depth)
+	}
+
+	if v != nil {
+		rv := reflect.ValueOf(v)
+		kind := rv.Kind()
+		if (kind == reflect.Slice && !rv.IsNil() || kind == reflect.Array) &&
+			rv.Type().Elem().Kind() != reflect.Uint8 &&
+			!rv.Type().Implements(marshalerType) && !rv.Type().Implements(textMarshalerType) {
+			return e.encodeArray(rv.Len(), func(i int) interface{} { return rv.Index(i).Interface() }, depth)
+		}
+	}
+	return e.encodeValue(v, depth)
+}
+
+func (e *streamEncoder) encodeObject(keys []string, value func(k string) interface{}, depth int) error {
+	if len(keys) == 0 {
+		return e.write("{}")
+	}
+	if err := e.write("{"); err != nil {
+		return err
+	}
+	for i, k := range keys {
+		if i > 0 {
+			if err := e.write(","); err != nil {
+				return err
+			}
+		}
+		if err := e.newline(depth + 1); err != nil {
+			return err
+		}
+		if err := e.encodeValue(k, depth+1); err != nil {
+			return err
+		}
+		separator := ":"
+		if e.indent {
+			separator = ": "
+		}
+		if err := e.write(separator); err != nil {
+			return err
+		}
+		if err := e.encode(value(k), depth+1); err != nil {
+			return err
+		}
+	}
+	if err := e.newline(depth); err != nil {
+		return err
+	}
+	return e.write("}")
+}
+
+func (e *streamEncoder) encodeArray(length int, item func(i int) interface{}, depth int) error {
+	if length == 0 {
+		return e.write("[]")
+	}
+	if err := e.write("["); err != nil {
+		return err
+	}
+	for i := 0; i < length; i++ {
+		if i > 0 {
+			if err := e.write(","); err != nil {
+				return err
+			}
+		}
+		if err := e.newline(depth + 1); err != nil {
+			return err
+		}
+		if err := e.encode(item(i), depth+1); err != nil {
+			return err
+		}
+	}
+	if err := e.newline(depth); err != nil {
+		return err
+	}
+	return e.write("]")
+}
+
+// encodeValue encodes value with encoding/json, indenting it as if it was
+// on provided depth.
+func (e *streamEncoder) encodeValue(v interface{}, depth int) error {
+	e.scratch.Reset()
+	enc := json.NewEncoder(&e.scratch)
+	if e.indent {
+		enc.SetIndent(strings.Repeat("\t", depth), "\t")
+	}
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	// json.Encoder always adds newline
+	e.scratch.Truncate(e.scratch.Len() - 1)
+	_, err := e.w.Write(e.scratch.Bytes())
+	return err
+}
+
+func (e *streamEncoder) newline(depth int) error {
+	if !e.indent {
+		return nil
+	}
+	return e.write("\n" + strings.Repeat("\t", depth))
+}
+
+func (e *streamEncoder) write(s string) error {
+	_, err := io.WriteString(e.w, s)
+	return err
+}
+
+// bodyAllowed returns true if response with provided status can have body
+// and Content-Length header.
+func bodyAllowed(httpCode int) bool {
+	switch {
+	case httpCode >= 100 && httpCode <= 199:
+		return false
+	case httpCode == http.StatusNoContent, httpCode == http.StatusNotModified:
+		return false
+	}
+	return true
+}
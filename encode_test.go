@@ -0,0 +1,251 @@
+package jsonresponse
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type benchmarkItem struct {
+	ID          int               `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Tags        []string          `json:"tags"`
+	Attributes  map[string]string `json:"attributes"`
+}
+
+func benchmarkPayload(items int) []benchmarkItem {
+	payload := make([]benchmarkItem, items)
+	for i := range payload {
+		payload[i] = benchmarkItem{
+			ID:          i,
+			Name:        "item " + strconv.Itoa(i),
+			Description: "Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor.",
+			Tags:        []string{"foo", "bar", "baz"},
+			Attributes:  map[string]string{"color": "red", "size": "large"},
+		}
+	}
+	return payload
+}
+
+// legacyResponse is how responses were written before encoding into pooled
+// buffers, kept for comparison in benchmarks. Response is transformed and
+// filtered same as by Response, only encoding differs.
+func legacyResponse(w http.ResponseWriter, httpCode int, r Response) {
+	s := current()
+	entry := s.transformerFor(httpCode)
+	headers, body := entry.transform(nil, r, httpCode)
+	body = r.filterData(body, entry.describer, s)
+	if needsConversion(body, s) {
+		body = toTree(body, s)
+	}
+	for k, v := range headers {
+		w.Header().Set(k, v)
+	}
+	w.Header().Set("Content-Type", s.contentType)
+	w.WriteHeader(httpCode)
+	b, err := json.Marshal(body)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Fprint(w, string(b)+"\n")
+}
+
+// discardWriter is http.ResponseWriter that throws away everything written to it.
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+func benchmarkLegacy(b *testing.B, items int) {
+	response := New(benchmarkPayload(items))
+	w := &discardWriter{header: http.Header{}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		legacyResponse(w, http.StatusOK, response)
+	}
+}
+
+func benchmarkPooled(b *testing.B, items int) {
+	response := New(benchmarkPayload(items))
+	w := &discardWriter{header: http.Header{}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		response.OK(w)
+	}
+}
+
+func BenchmarkLegacySmall(b *testing.B) { benchmarkLegacy(b, 1) }
+func BenchmarkLegacyLarge(b *testing.B) { benchmarkLegacy(b, 1000) }
+func BenchmarkPooledSmall(b *testing.B) { benchmarkPooled(b, 1) }
+func BenchmarkPooledLarge(b *testing.B) { benchmarkPooled(b, 1000) }
+
+func BenchmarkStreamedLarge(b *testing.B) {
+	SetBufferLimit(1<<30, OversizeStream)
+	defer SetBufferLimit(0, OversizeError)
+	benchmarkPooled(b, 1000)
+}
+
+func BenchmarkFastLarge(b *testing.B) {
+	SetFastEncoder(true)
+	defer SetFastEncoder(false)
+	benchmarkPooled(b, 1000)
+}
+
+func TestContentLengthSet(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	New(benchmarkPayload(3)).OK(recorder)
+	if cl := recorder.Header().Get("Content-Length"); cl != strconv.Itoa(recorder.Body.Len()) {
+		fmt.Printf("Content-Length %q does not match body length %d\n", cl, recorder.Body.Len())
+		t.Fail()
+	}
+}
+
+func TestContentLengthNotSetForNoContent(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	New("foo").NoContent(recorder)
+	if _, ok := recorder.HeaderMap["Content-Length"]; ok {
+		fmt.Println("Content-Length should not be set for 204 response.")
+		t.Fail()
+	}
+}
+
+func TestEncodeStreamSameAsEncodingJSON(t *testing.T) {
+	for _, v := range []interface{}{
+		nil,
+		"<b>&</b>",
+		[]int{},
+		[]string(nil),
+		map[string]interface{}{},
+		map[string]interface{}{"data": benchmarkPayload(2), "empty": []interface{}{}, "<key>": nil},
+		[]interface{}{map[string]interface{}{"a": [2]int{1, 2}}, []byte("bytes"), json.RawMessage(`{"raw":[1, 2]}`)},
+		toTree(benchmarkPayload(2), current()),
+	} {
+		for _, indent := range []bool{false, true} {
+			var expected bytes.Buffer
+			enc := json.NewEncoder(&expected)
+			if indent {
+				enc.SetIndent("", "\t")
+			}
+			if err := enc.Encode(v); err != nil {
+				fmt.Println("Failed to encode value: ", err)
+				t.Fail()
+			}
+			var got bytes.Buffer
+			if err := encodeStream(&got, v, indent, current()); err != nil {
+				fmt.Println("Failed to encode value: ", err)
+				t.Fail()
+			}
+			if got.String() != expected.String() {
+				fmt.Printf("Expected %s\nbut got  %s\n", expected.String(), got.String())
+				t.Fail()
+			}
+		}
+	}
+}
+
+func TestContentLengthSetInRespond(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	OK(recorder, nil)
+	if cl := recorder.Header().Get("Content-Length"); cl != strconv.Itoa(recorder.Body.Len()) {
+		fmt.Printf("Content-Length %q does not match body length %d\n", cl, recorder.Body.Len())
+		t.Fail()
+	}
+}
+
+func TestBufferLimitError(t *testing.T) {
+	SetBufferLimit(1024, OversizeError)
+	defer SetBufferLimit(0, OversizeError)
+	defer SetFastEncoder(false)
+
+	for _, fast := range []bool{false, true} {
+		SetFastEncoder(fast)
+		recorder := httptest.NewRecorder()
+		New(benchmarkPayload(100)).Header("Cache-Control", "max-age=60").OK(recorder)
+		if recorder.Code != http.StatusInternalServerError {
+			fmt.Printf("Fast encoder %v: HTTP code did not match, got %d, expected: %d\n", fast, recorder.Code, http.StatusInternalServerError)
+			t.Fail()
+		}
+		expected := `{"code":500,"message":"Internal Server Error"}`
+		if got := strings.TrimSpace(recorder.Body.String()); got != expected {
+			fmt.Printf("Fast encoder %v: expected %s\nbut got  %s\n", fast, expected, got)
+			t.Fail()
+		}
+		if cc := recorder.Header().Get("Cache-Control"); cc != "" {
+			fmt.Printf("Fast encoder %v: headers of response should not be sent with error, got Cache-Control %q\n", fast, cc)
+			t.Fail()
+		}
+
+		recorder = httptest.NewRecorder()
+		New(benchmarkPayload(1)).OK(recorder)
+		if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Length") == "" {
+			fmt.Printf("Fast encoder %v: response smaller than limit should be sent with Content-Length.\n", fast)
+			t.Fail()
+		}
+	}
+}
+
+func TestBufferLimitStream(t *testing.T) {
+	SetBufferLimit(1024, OversizeStream)
+	defer SetBufferLimit(0, OversizeError)
+	defer SetFastEncoder(false)
+
+	payload := benchmarkPayload(100)
+	for _, fast := range []bool{false, true} {
+		SetFastEncoder(fast)
+		recorder := httptest.NewRecorder()
+		New(payload).Created(recorder)
+		if recorder.Code != http.StatusCreated {
+			fmt.Printf("Fast encoder %v: HTTP code did not match, got %d, expected: %d\n", fast, recorder.Code, http.StatusCreated)
+			t.Fail()
+		}
+		if _, ok := recorder.HeaderMap["Content-Length"]; ok {
+			fmt.Printf("Fast encoder %v: Content-Length should not be set for streamed response.\n", fast)
+			t.Fail()
+		}
+		expected, _ := json.Marshal(map[string]interface{}{"data": payload})
+		if got := strings.TrimSpace(recorder.Body.String()); got != string(expected) {
+			fmt.Printf("Fast encoder %v: streamed response does not match encoded data.\n", fast)
+			t.Fail()
+		}
+	}
+}
+
+func TestBufferLimitAppliedToSentBody(t *testing.T) {
+	SetBufferLimit(1024, OversizeError)
+	defer SetBufferLimit(0, OversizeError)
+	SetSigning(SigningOptions{Digest: DigestSHA256})
+	defer SetSigning(SigningOptions{})
+
+	// JWS is larger than encoded body, which is within limit
+	data := strings.Repeat("x", 900)
+	for name, response := range map[string]Response{
+		"canonical": New(benchmarkPayload(100)).Canonical(),
+		"jws":       New(data).JWS(JWSOptions{Key: HS256Key([]byte("secret"))}),
+	} {
+		recorder := httptest.NewRecorder()
+		response.OK(recorder)
+		if recorder.Code != http.StatusInternalServerError {
+			fmt.Printf("%s: HTTP code did not match, got %d, expected: %d\n", name, recorder.Code, http.StatusInternalServerError)
+			t.Fail()
+		}
+		if ct := recorder.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+			fmt.Printf("%s: unexpected Content-Type of error %q\n", name, ct)
+			t.Fail()
+		}
+		if digest, err := contentDigest(DigestSHA256, recorder.Body.Bytes()); err != nil || recorder.Header().Get("Content-Digest") != digest {
+			fmt.Printf("%s: Content-Digest should be digest of error, got %q\n", name, recorder.Header().Get("Content-Digest"))
+			t.Fail()
+		}
+	}
+}
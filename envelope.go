@@ -0,0 +1,179 @@
+package jsonresponse
+
+import (
+	"reflect"
+	"sort"
+)
+
+// transformerEntry is transformer set via one of setters, with describer of
+// path of data in its result, if transformer declares it (see Envelope).
+type transformerEntry struct {
+	transform RequestTransformer
+	describer DataPathDescriber
+}
+
+// DataPath returns path of keys under which transformer used for responses
+// with provided status code places data of response, e.g. ["data"] for default
+// transformer. Empty path means that data is not wrapped in envelope. Path is
+// known only for transformers that declare it (see Envelope and
+// DataPathDescriber), for other transformers nil is returned.
+func DataPath(httpCode int) []string {
+	entry := transformerFor(httpCode)
+	if entry.transform == nil {
+		return []string{}
+	}
+	if entry.describer == nil {
+		return nil
+	}
+	path := entry.describer.DataPath()
+	if path == nil {
+		path = []string{}
+	}
+	return path
+}
+
+// maxDataDepth is depth of envelope up to which data is looked for in it.
+const maxDataDepth = 8
+
+// locateData returns path of keys under which data is placed in body produced
+// by transformer that does not declare it. Data is looked up by its identity,
+// or by its type if transformer placed modified copy of it in body (e.g.
+// RequestIDInBody). Values in arrays are not looked into. Fields of structs
+// are named with provided naming.
+func locateData(body, data interface{}, naming keyNaming) ([]string, bool) {
+	dv := reflect.ValueOf(data)
+	if path, ok := findData(reflect.ValueOf(body), func(v reflect.Value) bool { return sameValue(v, dv) }, naming, 0); ok {
+		return path, true
+	}
+	t := dv.Type()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, false
+	}
+	return findData(reflect.ValueOf(body), func(v reflect.Value) bool { return v.Type() == dv.Type() }, naming, 0)
+}
+
+func findData(v reflect.Value, match func(reflect.Value) bool, naming keyNaming, depth int) ([]string, bool) {
+	if !v.IsValid() || depth > maxDataDepth {
+		return nil, false
+	}
+	if match(v) {
+		return []string{}, true
+	}
+	found := func(key string, child reflect.Value) ([]string, bool) {
+		path, ok := findData(child, match, naming, depth+1)
+		if !ok {
+			return nil, false
+		}
+		return append([]string{key}, path...), true
+	}
+	if v.Type() == objectType && !v.IsNil() {
+		o := v.Interface().(*object)
+		for _, k := range o.keys {
+			if path, ok := found(k, reflect.ValueOf(o.values[k])); ok {
+				return path, true
+			}
+		}
+		return nil, false
+	}
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return nil, false
+		}
+		return findData(v.Elem(), match, naming, depth+1)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		keys := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if path, ok := found(k, v.MapIndex(reflect.ValueOf(k).Convert(v.Type().Key()))); ok {
+				return path, true
+			}
+		}
+	case reflect.Struct:
+		for _, f := range cachedFields(v.Type(), naming) {
+			fv, ok := fieldByIndex(v, f.index)
+			if !ok {
+				continue
+			}
+			if path, ok := found(f.name, fv); ok {
+				return path, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// sameValue reports whether v is value data, which is compared by identity
+// for pointers, maps and slices.
+func sameValue(v, data reflect.Value) bool {
+	if !v.IsValid() || !data.IsValid() || v.Type() != data.Type() || !v.CanInterface() {
+		return false
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map:
+		return !v.IsNil() && v.Pointer() == data.Pointer()
+	case reflect.Slice:
+		return !v.IsNil() && v.Pointer() == data.Pointer() && v.Len() == data.Len()
+	}
+	return reflect.DeepEqual(v.Interface(), data.Interface())
+}
+
+// replaceAt returns copy of body in which value at path is replaced by result
+// of replace. Envelope that is neither map nor object is converted to object
+// on the way, while values that are not on the path are left as they are.
+// If there is no value at path, false is returned. Fields of structs are
+// named with provided naming.
+func replaceAt(body interface{}, path []string, replace func(interface{}) interface{}, naming keyNaming) (interface{}, bool) {
+	if len(path) == 0 {
+		return replace(body), true
+	}
+	switch b := body.(type) {
+	case map[string]interface{}:
+		value, ok := b[path[0]]
+		if !ok {
+			return nil, false
+		}
+		replaced, ok := replaceAt(value, path[1:], replace, naming)
+		if !ok {
+			return nil, false
+		}
+		c := make(map[string]interface{}, len(b))
+		for k, v := range b {
+			c[k] = v
+		}
+		c[path[0]] = replaced
+		return c, true
+	case *object:
+		value, ok := b.values[path[0]]
+		if !ok {
+			return nil, false
+		}
+		replaced, ok := replaceAt(value, path[1:], replace, naming)
+		if !ok {
+			return nil, false
+		}
+		c := newObject(len(b.keys))
+		for _, k := range b.keys {
+			c.set(k, b.values[k])
+		}
+		c.values[path[0]] = replaced
+		return c, true
+	}
+	if body == nil {
+		return nil, false
+	}
+	c := &converter{naming: naming, shallow: true}
+	if o, ok := c.tree(body).(*object); ok {
+		return replaceAt(o, path, replace, naming)
+	}
+	return nil, false
+}
@@ -0,0 +1,50 @@
+// Package expvarhooks provides jsonresponse hooks that count responses in
+// expvar variables.
+//
+// Example:
+//
+//	jsonresponse.SetHooks(expvarhooks.New("responses"))
+package expvarhooks
+
+import (
+	"context"
+	"expvar"
+	"strconv"
+	"time"
+)
+
+// Hooks counts responses per status code, bytes of sent bodies and encode
+// failures. Counters are published as expvar map with keys "status" (map
+// of status codes to number of responses), "bytes" and "encode_failures".
+type Hooks struct {
+	status         *expvar.Map
+	bytes          *expvar.Int
+	encodeFailures *expvar.Int
+}
+
+// New creates hooks and publishes their counters under provided name. Like
+// expvar.Publish, it panics if name is already used.
+func New(name string) *Hooks {
+	h := &Hooks{
+		status:         new(expvar.Map).Init(),
+		bytes:          new(expvar.Int),
+		encodeFailures: new(expvar.Int),
+	}
+	counters := expvar.NewMap(name)
+	counters.Set("status", h.status)
+	counters.Set("bytes", h.bytes)
+	counters.Set("encode_failures", h.encodeFailures)
+	return h
+}
+
+// BeforeEncode does nothing, responses are counted after they are written.
+func (h *Hooks) BeforeEncode(ctx context.Context, status int, data interface{}) {}
+
+// AfterWrite counts written response.
+func (h *Hooks) AfterWrite(ctx context.Context, status int, bytes int, duration time.Duration, err error) {
+	h.status.Add(strconv.Itoa(status), 1)
+	h.bytes.Add(int64(bytes))
+	if err != nil {
+		h.encodeFailures.Add(1)
+	}
+}
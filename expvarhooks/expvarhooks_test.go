@@ -0,0 +1,29 @@
+package expvarhooks
+
+import (
+	"expvar"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/delicb/jsonresponse"
+)
+
+func TestHooks(t *testing.T) {
+	jsonresponse.SetHooks(New("test_responses"))
+	defer jsonresponse.SetHooks()
+
+	jsonresponse.New("value").OK(httptest.NewRecorder())
+	jsonresponse.New("value").OK(httptest.NewRecorder())
+	jsonresponse.NotFound(httptest.NewRecorder(), nil)
+	func() {
+		defer func() { recover() }()
+		jsonresponse.New(func() {}).OK(httptest.NewRecorder())
+	}()
+
+	expected := `{"bytes": 69, "encode_failures": 1, "status": {"200": 3, "404": 1}}`
+	if got := expvar.Get("test_responses").String(); got != expected {
+		fmt.Printf("Expected %s\nbut got  %s\n", expected, got)
+		t.Fail()
+	}
+}
@@ -0,0 +1,886 @@
+package jsonresponse
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// Fast encoder builds encoding plan for each type on first use and caches it.
+// Plan contains everything encoding/json finds out via reflection on each call
+// (fields, their order, tag options and jsonresponse options), so values are
+// encoded directly, with redaction, views and projection of fields applied
+// while encoding, instead of converting values to tree first.
+// Output is byte-identical to output of encoding/json.
+
+// Some details of encoding/json output differ between Go versions, so they
+// are detected once instead of assuming one version.
+var (
+	// invalid UTF-8 is replaced with escaped or literal U+FFFD
+	escapedReplacementChar = jsonOutput("\xff") == `"\ufffd"`
+	// \b and \f have short escapes instead of \u0008 and \u000c
+	shortEscapes = jsonOutput("\b") == `"\b"`
+	// map keys implementing encoding.TextMarshaler are encoded using it,
+	// even if they are strings
+	textKeysFirst = jsonOutput(map[probeKey]int{"key": 0}) == `{"text":0}`
+)
+
+type probeKey string
+
+func (probeKey) MarshalText() ([]byte, error) {
+	return []byte("text"), nil
+}
+
+func jsonOutput(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// maxPtrDepth is depth of pointers after which cycle is assumed.
+const maxPtrDepth = 1000
+
+// filtered wraps response data in result of transformer, which has to be
+// filtered by view and/or projected to selected fields. Fast encoder applies
+// filters while encoding, while any other encoder falls back to MarshalJSON.
+type filtered struct {
+	data      interface{}
+	view      string
+	selection fieldSelection
+	// redactKeys is set if keys are redacted by patterns in data
+	redactKeys bool
+	// original is data of response, if transformer placed its modified copy
+	// in result (e.g. RequestIDInBody); fields that are not in original data
+	// are kept regardless of selection
+	original interface{}
+	// s is snapshot of configuration with which data is written
+	s *settings
+}
+
+// tree converts data to tree with filters applied.
+func (f *filtered) tree() interface{} {
+	c := newConverter(f.view, f.s)
+	if !f.redactKeys {
+		c.redaction = c.redaction.withoutKeys()
+	}
+	tree := c.tree(f.data)
+	if f.selection != nil {
+		var original interface{}
+		if f.original != nil {
+			original = c.tree(f.original)
+		}
+		tree = prune(tree, f.selection, original)
+	}
+	return tree
+}
+
+// MarshalJSON converts data to tree with filters applied.
+func (f *filtered) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.tree())
+}
+
+var filteredType = reflect.TypeOf(&filtered{})
+
+// encoderFunc encodes value of single type.
+type encoderFunc func(e *fastEncoder, v reflect.Value, opts encoderOpts) error
+
+type encoderOpts struct {
+	// quoted is set for fields with ",string" option
+	quoted bool
+	// selection of fields from fields query parameter, nil means all fields
+	selection fieldSelection
+}
+
+// fastEncoder holds state of single encoding.
+type fastEncoder struct {
+	buf       []byte
+	redaction redactionOptions
+	naming    keyNaming
+	view      string
+	ptrDepth  int
+	// entries of maps that are being encoded, which are reused between maps
+	entries mapEntries
+	// sorted are entries of map that is sorted, kept here so sorting them
+	// does not allocate
+	sorted mapEntries
+	// w receives encoded parts of value larger than limit, see flush
+	w     io.Writer
+	limit int
+}
+
+var fastEncoderPool = sync.Pool{
+	New: func() interface{} {
+		return &fastEncoder{}
+	},
+}
+
+// encodeFast is same as encode, but uses fast encoder instead of encoding/json.
+func encodeFast(w io.Writer, data interface{}, indent bool, s *settings) error {
+	return encodeFastWithLimit(w, data, indent, 0, s)
+}
+
+// encodeFastWithLimit is same as encodeFast, except that elements of arrays
+// and maps are written to writer whenever encoded part of data exceeds limit,
+// so large data is never encoded to memory at once. Limit is not applied to
+// indented data, which is indented when it is encoded whole.
+func encodeFastWithLimit(w io.Writer, data interface{}, indent bool, limit int, s *settings) error {
+	e := fastEncoderPool.Get().(*fastEncoder)
+	defer func() {
+		e.w = nil
+		if cap(e.buf) <= maxPooledBufferSize {
+			fastEncoderPool.Put(e)
+		}
+	}()
+	e.buf = e.buf[:0]
+	e.redaction = s.redaction.withoutKeys()
+	e.naming = s.keyNaming
+	e.view = ""
+	e.ptrDepth = 0
+	e.w = w
+	e.limit = limit
+	if indent {
+		e.limit = 0
+	}
+
+	if err := e.encode(reflect.ValueOf(data), encoderOpts{}); err != nil {
+		return err
+	}
+	if indent {
+		out := getBuffer()
+		defer putBuffer(out)
+		if err := json.Indent(out, e.buf, "", "\t"); err != nil {
+			return err
+		}
+		out.WriteByte('\n')
+		_, err := w.Write(out.Bytes())
+		return err
+	}
+	e.buf = append(e.buf, '\n')
+	_, err := w.Write(e.buf)
+	return err
+}
+
+// flush writes encoded part of data to writer once it exceeds limit.
+func (e *fastEncoder) flush() error {
+	if e.limit <= 0 || len(e.buf) <= e.limit {
+		return nil
+	}
+	_, err := e.w.Write(e.buf)
+	e.buf = e.buf[:0]
+	return err
+}
+
+func (e *fastEncoder) encode(v reflect.Value, opts encoderOpts) error {
+	if !v.IsValid() {
+		e.buf = append(e.buf, "null"...)
+		return nil
+	}
+	if v.Type() == filteredType && !v.IsNil() {
+		f := v.Interface().(*filtered)
+		if f.original != nil && f.selection != nil {
+			return e.encode(reflect.ValueOf(f.tree()), encoderOpts{})
+		}
+		view, redaction := e.view, e.redaction
+		e.view = f.view
+		if f.redactKeys {
+			e.redaction = f.s.redaction
+		}
+		err := e.encode(reflect.ValueOf(f.data), encoderOpts{selection: f.selection})
+		e.view, e.redaction = view, redaction
+		return err
+	}
+	return typeEncoder(v.Type())(e, v, opts)
+}
+
+var encoderCache sync.Map // map[reflect.Type]encoderFunc
+
+// typeEncoder returns cached encoder for type, building it on first use.
+func typeEncoder(t reflect.Type) encoderFunc {
+	if f, ok := encoderCache.Load(t); ok {
+		return f.(encoderFunc)
+	}
+
+	// recursive types use indirect function until real one is built
+	var (
+		wg sync.WaitGroup
+		f  encoderFunc
+	)
+	wg.Add(1)
+	fi, loaded := encoderCache.LoadOrStore(t, encoderFunc(func(e *fastEncoder, v reflect.Value, opts encoderOpts) error {
+		wg.Wait()
+		return f(e, v, opts)
+	}))
+	if loaded {
+		return fi.(encoderFunc)
+	}
+
+	f = newTypeEncoder(t, true)
+	wg.Done()
+	encoderCache.Store(t, f)
+	return f
+}
+
+func newTypeEncoder(t reflect.Type, allowAddr bool) encoderFunc {
+	if t.Kind() != reflect.Ptr && allowAddr && reflect.PtrTo(t).Implements(marshalerType) {
+		return condAddrEncoder(addrMarshalerEncoder, newTypeEncoder(t, false))
+	}
+	if t.Implements(marshalerType) {
+		return marshalerEncoder
+	}
+	if t.Kind() != reflect.Ptr && allowAddr && reflect.PtrTo(t).Implements(textMarshalerType) {
+		return condAddrEncoder(addrTextMarshalerEncoder, newTypeEncoder(t, false))
+	}
+	if t.Implements(textMarshalerType) {
+		return textMarshalerEncoder
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return boolEncoder
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return intEncoder
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return uintEncoder
+	case reflect.Float32:
+		return floatEncoder(32)
+	case reflect.Float64:
+		return floatEncoder(64)
+	case reflect.String:
+		return stringEncoder
+	case reflect.Interface:
+		return interfaceEncoder
+	case reflect.Struct:
+		return newStructEncoder(t)
+	case reflect.Map:
+		return newMapEncoder(t)
+	case reflect.Slice:
+		return newSliceEncoder(t)
+	case reflect.Array:
+		return newArrayEncoder(t)
+	case reflect.Ptr:
+		return newPtrEncoder(t)
+	}
+	return unsupportedTypeEncoder
+}
+
+func condAddrEncoder(canAddr, elseEnc encoderFunc) encoderFunc {
+	return func(e *fastEncoder, v reflect.Value, opts encoderOpts) error {
+		if v.CanAddr() {
+			return canAddr(e, v, opts)
+		}
+		return elseEnc(e, v, opts)
+	}
+}
+
+func unsupportedTypeEncoder(e *fastEncoder, v reflect.Value, _ encoderOpts) error {
+	return &json.UnsupportedTypeError{Type: v.Type()}
+}
+
+func marshalerEncoder(e *fastEncoder, v reflect.Value, _ encoderOpts) error {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		e.buf = append(e.buf, "null"...)
+		return nil
+	}
+	m, ok := v.Interface().(json.Marshaler)
+	if !ok {
+		e.buf = append(e.buf, "null"...)
+		return nil
+	}
+	return e.writeMarshaled(v.Type(), m)
+}
+
+func addrMarshalerEncoder(e *fastEncoder, v reflect.Value, _ encoderOpts) error {
+	va := v.Addr()
+	if va.IsNil() {
+		e.buf = append(e.buf, "null"...)
+		return nil
+	}
+	return e.writeMarshaled(v.Type(), va.Interface().(json.Marshaler))
+}
+
+// writeMarshaled writes output of MarshalJSON compacted and with HTML
+// characters escaped, same as encoding/json.
+func (e *fastEncoder) writeMarshaled(t reflect.Type, m json.Marshaler) error {
+	b, err := m.MarshalJSON()
+	if err != nil {
+		return &json.MarshalerError{Type: t, Err: err}
+	}
+	compact := getBuffer()
+	defer putBuffer(compact)
+	if err := json.Compact(compact, b); err != nil {
+		return &json.MarshalerError{Type: t, Err: err}
+	}
+	e.buf = appendHTMLEscaped(e.buf, compact.Bytes())
+	return nil
+}
+
+func textMarshalerEncoder(e *fastEncoder, v reflect.Value, _ encoderOpts) error {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		e.buf = append(e.buf, "null"...)
+		return nil
+	}
+	m, ok := v.Interface().(encoding.TextMarshaler)
+	if !ok {
+		e.buf = append(e.buf, "null"...)
+		return nil
+	}
+	return e.writeText(v.Type(), m)
+}
+
+func addrTextMarshalerEncoder(e *fastEncoder, v reflect.Value, _ encoderOpts) error {
+	va := v.Addr()
+	if va.IsNil() {
+		e.buf = append(e.buf, "null"...)
+		return nil
+	}
+	return e.writeText(v.Type(), va.Interface().(encoding.TextMarshaler))
+}
+
+func (e *fastEncoder) writeText(t reflect.Type, m encoding.TextMarshaler) error {
+	b, err := m.MarshalText()
+	if err != nil {
+		return &json.MarshalerError{Type: t, Err: err}
+	}
+	e.buf = appendString(e.buf, string(b))
+	return nil
+}
+
+func boolEncoder(e *fastEncoder, v reflect.Value, opts encoderOpts) error {
+	e.quote(opts)
+	e.buf = strconv.AppendBool(e.buf, v.Bool())
+	e.quote(opts)
+	return nil
+}
+
+func intEncoder(e *fastEncoder, v reflect.Value, opts encoderOpts) error {
+	e.quote(opts)
+	e.buf = strconv.AppendInt(e.buf, v.Int(), 10)
+	e.quote(opts)
+	return nil
+}
+
+func uintEncoder(e *fastEncoder, v reflect.Value, opts encoderOpts) error {
+	e.quote(opts)
+	e.buf = strconv.AppendUint(e.buf, v.Uint(), 10)
+	e.quote(opts)
+	return nil
+}
+
+func floatEncoder(bits int) encoderFunc {
+	return func(e *fastEncoder, v reflect.Value, opts encoderOpts) error {
+		f := v.Float()
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return &json.UnsupportedValueError{Value: v, Str: strconv.FormatFloat(f, 'g', -1, bits)}
+		}
+		e.quote(opts)
+		e.buf = appendFloat(e.buf, f, bits)
+		e.quote(opts)
+		return nil
+	}
+}
+
+// appendFloat formats float same way as encoding/json, which is same as
+// ES6 for float64.
+func appendFloat(b []byte, f float64, bits int) []byte {
+	abs := math.Abs(f)
+	format := byte('f')
+	if abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	b = strconv.AppendFloat(b, f, format, -1, bits)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b
+}
+
+var numberType = reflect.TypeOf(json.Number(""))
+
+func stringEncoder(e *fastEncoder, v reflect.Value, opts encoderOpts) error {
+	if v.Type() == numberType {
+		number := v.String()
+		if number == "" {
+			number = "0"
+		}
+		if !isValidNumber(number) {
+			return fmt.Errorf("json: invalid number literal %q", number)
+		}
+		e.quote(opts)
+		e.buf = append(e.buf, number...)
+		e.quote(opts)
+		return nil
+	}
+	if opts.quoted {
+		e.buf = appendString(e.buf, string(appendString(nil, v.String())))
+		return nil
+	}
+	e.buf = appendString(e.buf, v.String())
+	return nil
+}
+
+func (e *fastEncoder) quote(opts encoderOpts) {
+	if opts.quoted {
+		e.buf = append(e.buf, '"')
+	}
+}
+
+func interfaceEncoder(e *fastEncoder, v reflect.Value, opts encoderOpts) error {
+	if v.IsNil() {
+		e.buf = append(e.buf, "null"...)
+		return nil
+	}
+	return e.encode(v.Elem(), encoderOpts{selection: opts.selection})
+}
+
+// structField is field with everything needed to encode it.
+type structField struct {
+	field
+	// key is encoded name of field followed by colon
+	key []byte
+	enc encoderFunc
+}
+
+func newStructEncoder(t reflect.Type) encoderFunc {
+	// names of fields depend on naming, so plan is built for each of them
+	var plans sync.Map // map[string][]structField
+	planFor := func(naming keyNaming) []structField {
+		if p, ok := plans.Load(naming.id); ok {
+			return p.([]structField)
+		}
+		fields := cachedFields(t, naming)
+		plan := make([]structField, len(fields))
+		for i, f := range fields {
+			plan[i] = structField{
+				field: f,
+				key:   append(appendString(nil, f.name), ':'),
+				enc:   typeEncoder(typeByIndex(t, f.index)),
+			}
+		}
+		p, _ := plans.LoadOrStore(naming.id, plan)
+		return p.([]structField)
+	}
+
+	return func(e *fastEncoder, v reflect.Value, opts encoderOpts) error {
+		plan := planFor(e.naming)
+		e.buf = append(e.buf, '{')
+		first := true
+		for i := range plan {
+			f := &plan[i]
+			if !f.visibleIn(e.view) {
+				continue
+			}
+			var selection fieldSelection
+			if opts.selection != nil {
+				child, ok := opts.selection[f.name]
+				if !ok {
+					continue
+				}
+				selection = child
+			}
+			fv, ok := fieldByIndex(v, f.index)
+			if !ok {
+				continue
+			}
+			if f.omitEmpty && isEmptyValue(fv) || f.omitZero && isZeroValue(fv) {
+				continue
+			}
+			redacted := e.redaction.redacts(f.name, f.redact)
+			if redacted && e.redaction.drop {
+				continue
+			}
+			if !first {
+				e.buf = append(e.buf, ',')
+			}
+			first = false
+			e.buf = append(e.buf, f.key...)
+			if redacted {
+				e.buf = appendString(e.buf, RedactedValue)
+				continue
+			}
+			if err := f.enc(e, fv, encoderOpts{quoted: f.quoted, selection: selection}); err != nil {
+				return err
+			}
+		}
+		e.buf = append(e.buf, '}')
+		return nil
+	}
+}
+
+// typeByIndex returns type of field with provided index, following
+// pointers to embedded structs.
+func typeByIndex(t reflect.Type, index []int) reflect.Type {
+	for _, i := range index {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		t = t.Field(i).Type
+	}
+	return t
+}
+
+func newMapEncoder(t reflect.Type) encoderFunc {
+	switch t.Key().Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+	default:
+		if !t.Key().Implements(textMarshalerType) {
+			return unsupportedTypeEncoder
+		}
+	}
+	elemEnc := typeEncoder(t.Elem())
+	// map values are not addressable, so they can be copied to slice (which
+	// needs less allocations) only if that does not change their encoding
+	valuesType := reflect.SliceOf(t.Elem())
+	copyValues := !addressSensitive(t.Elem())
+
+	return func(e *fastEncoder, v reflect.Value, opts encoderOpts) error {
+		if v.IsNil() {
+			e.buf = append(e.buf, "null"...)
+			return nil
+		}
+		if e.ptrDepth++; e.ptrDepth > maxPtrDepth {
+			return &json.UnsupportedValueError{Value: v, Str: fmt.Sprintf("encountered a cycle via %s", v.Type())}
+		}
+		n := v.Len()
+		start := len(e.entries)
+		defer e.releaseEntries(start)
+		var values reflect.Value
+		if copyValues {
+			values = reflect.MakeSlice(valuesType, n, n)
+		}
+		key := reflect.New(t.Key()).Elem()
+		iter := v.MapRange()
+		for i := 0; iter.Next(); i++ {
+			key.SetIterKey(iter)
+			k, ok := mapKey(key)
+			if !ok {
+				return &json.MarshalerError{Type: key.Type(), Err: fmt.Errorf("can not encode map key")}
+			}
+			en := mapEntry{key: k}
+			if copyValues {
+				en.value = values.Index(i)
+				en.value.SetIterValue(iter)
+			} else {
+				en.value = iter.Value()
+			}
+			e.entries = append(e.entries, en)
+		}
+		// entries of nested maps are appended after these, so they stay intact
+		entries := e.entries[start:]
+		e.sorted = entries
+		sort.Sort(&e.sorted)
+		e.sorted = nil
+
+		e.buf = append(e.buf, '{')
+		first := true
+		for _, en := range entries {
+			var selection fieldSelection
+			if opts.selection != nil {
+				child, ok := opts.selection[en.key]
+				if !ok {
+					continue
+				}
+				selection = child
+			}
+			redacted := e.redaction.redacts(en.key, false)
+			if redacted && e.redaction.drop {
+				continue
+			}
+			if !first {
+				e.buf = append(e.buf, ',')
+			}
+			first = false
+			e.buf = appendString(e.buf, en.key)
+			e.buf = append(e.buf, ':')
+			if redacted {
+				e.buf = appendString(e.buf, RedactedValue)
+				continue
+			}
+			if err := elemEnc(e, en.value, encoderOpts{selection: selection}); err != nil {
+				return err
+			}
+			if err := e.flush(); err != nil {
+				return err
+			}
+		}
+		e.buf = append(e.buf, '}')
+		e.ptrDepth--
+		return nil
+	}
+}
+
+// releaseEntries removes entries of map from encoder, so pooled encoder does
+// not keep values of maps it encoded.
+func (e *fastEncoder) releaseEntries(start int) {
+	for i := start; i < len(e.entries); i++ {
+		e.entries[i] = mapEntry{}
+	}
+	e.entries = e.entries[:start]
+}
+
+type mapEntry struct {
+	key   string
+	value reflect.Value
+}
+
+type mapEntries []mapEntry
+
+func (m mapEntries) Len() int           { return len(m) }
+func (m mapEntries) Less(i, j int) bool { return m[i].key < m[j].key }
+func (m mapEntries) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+
+// addressSensitive returns true if encoding of value of provided type depends
+// on whether it is addressable, since its methods with pointer receiver
+// are used only for addressable values.
+func addressSensitive(t reflect.Type) bool {
+	if t.Kind() != reflect.Ptr {
+		p := reflect.PtrTo(t)
+		if p.Implements(marshalerType) || p.Implements(textMarshalerType) {
+			return true
+		}
+	}
+	switch t.Kind() {
+	case reflect.Array:
+		return addressSensitive(t.Elem())
+	case reflect.Struct:
+		for _, f := range cachedFields(t, keyNaming{}) {
+			if addressSensitive(typeByIndex(t, f.index)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func newSliceEncoder(t reflect.Type) encoderFunc {
+	if t.Elem().Kind() == reflect.Uint8 {
+		p := reflect.PtrTo(t.Elem())
+		if !p.Implements(marshalerType) && !p.Implements(textMarshalerType) {
+			return byteSliceEncoder
+		}
+	}
+	arrayEnc := newArrayEncoder(t)
+	return func(e *fastEncoder, v reflect.Value, opts encoderOpts) error {
+		if v.IsNil() {
+			e.buf = append(e.buf, "null"...)
+			return nil
+		}
+		if e.ptrDepth++; e.ptrDepth > maxPtrDepth {
+			return &json.UnsupportedValueError{Value: v, Str: fmt.Sprintf("encountered a cycle via %s", v.Type())}
+		}
+		err := arrayEnc(e, v, opts)
+		e.ptrDepth--
+		return err
+	}
+}
+
+func byteSliceEncoder(e *fastEncoder, v reflect.Value, _ encoderOpts) error {
+	if v.IsNil() {
+		e.buf = append(e.buf, "null"...)
+		return nil
+	}
+	b := v.Bytes()
+	n := len(e.buf) + 1
+	e.buf = append(e.buf, make([]byte, base64.StdEncoding.EncodedLen(len(b))+2)...)
+	e.buf[n-1] = '"'
+	base64.StdEncoding.Encode(e.buf[n:], b)
+	e.buf[len(e.buf)-1] = '"'
+	return nil
+}
+
+func newArrayEncoder(t reflect.Type) encoderFunc {
+	elemEnc := typeEncoder(t.Elem())
+	return func(e *fastEncoder, v reflect.Value, opts encoderOpts) error {
+		e.buf = append(e.buf, '[')
+		n := v.Len()
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				e.buf = append(e.buf, ',')
+			}
+			if err := elemEnc(e, v.Index(i), encoderOpts{selection: opts.selection}); err != nil {
+				return err
+			}
+			if err := e.flush(); err != nil {
+				return err
+			}
+		}
+		e.buf = append(e.buf, ']')
+		return nil
+	}
+}
+
+func newPtrEncoder(t reflect.Type) encoderFunc {
+	elemEnc := typeEncoder(t.Elem())
+	return func(e *fastEncoder, v reflect.Value, opts encoderOpts) error {
+		if v.IsNil() {
+			e.buf = append(e.buf, "null"...)
+			return nil
+		}
+		if e.ptrDepth++; e.ptrDepth > maxPtrDepth {
+			return &json.UnsupportedValueError{Value: v, Str: fmt.Sprintf("encountered a cycle via %s", v.Type())}
+		}
+		err := elemEnc(e, v.Elem(), opts)
+		e.ptrDepth--
+		return err
+	}
+}
+
+const hex = "0123456789abcdef"
+
+// appendString appends JSON string to dst, escaping it same way as
+// encoding/json does with HTML escaping turned on.
+func appendString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if htmlSafe[b] {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '\\', '"':
+				dst = append(dst, '\\', b)
+			case '\b':
+				dst = appendShortEscape(dst, b, 'b')
+			case '\f':
+				dst = appendShortEscape(dst, b, 'f')
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				// control characters and <, >, &
+				dst = append(dst, '\\', 'u', '0', '0', hex[b>>4], hex[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		n := len(s) - i
+		if n > utf8.UTFMax {
+			n = utf8.UTFMax
+		}
+		c, size := utf8.DecodeRuneInString(s[i : i+n])
+		if c == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			if escapedReplacementChar {
+				dst = append(dst, `\ufffd`...)
+			} else {
+				dst = append(dst, "\ufffd"...)
+			}
+			i += size
+			start = i
+			continue
+		}
+		// U+2028 and U+2029 are valid JSON, but not valid JavaScript
+		if c == '\u2028' || c == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hex[c&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	dst = append(dst, '"')
+	return dst
+}
+
+func appendShortEscape(dst []byte, b, short byte) []byte {
+	if shortEscapes {
+		return append(dst, '\\', short)
+	}
+	return append(dst, '\\', 'u', '0', '0', hex[b>>4], hex[b&0xF])
+}
+
+// htmlSafe contains ASCII characters that do not have to be escaped.
+var htmlSafe [utf8.RuneSelf]bool
+
+func init() {
+	for b := 0x20; b < utf8.RuneSelf; b++ {
+		htmlSafe[b] = b != '"' && b != '\\' && b != '<' && b != '>' && b != '&'
+	}
+}
+
+// appendHTMLEscaped appends JSON to dst with characters that are not safe in
+// HTML escaped, same as json.HTMLEscape.
+func appendHTMLEscaped(dst, src []byte) []byte {
+	start := 0
+	for i, c := range src {
+		if c == '<' || c == '>' || c == '&' {
+			dst = append(dst, src[start:i]...)
+			dst = append(dst, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xF])
+			start = i + 1
+		}
+		// U+2028 and U+2029 (E2 80 A8 and E2 80 A9)
+		if c == 0xE2 && i+2 < len(src) && src[i+1] == 0x80 && src[i+2]&^1 == 0xA8 {
+			dst = append(dst, src[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hex[src[i+2]&0xF])
+			start = i + 3
+		}
+	}
+	return append(dst, src[start:]...)
+}
+
+// isValidNumber reports whether s is valid JSON number literal.
+func isValidNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	if s[0] == '-' {
+		s = s[1:]
+		if s == "" {
+			return false
+		}
+	}
+	switch {
+	case s[0] == '0':
+		s = s[1:]
+	case '1' <= s[0] && s[0] <= '9':
+		s = s[1:]
+		for len(s) > 0 && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
+	default:
+		return false
+	}
+	if len(s) >= 2 && s[0] == '.' && '0' <= s[1] && s[1] <= '9' {
+		s = s[2:]
+		for len(s) > 0 && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
+	}
+	if len(s) >= 2 && (s[0] == 'e' || s[0] == 'E') {
+		s = s[1:]
+		if s[0] == '+' || s[0] == '-' {
+			s = s[1:]
+			if s == "" {
+				return false
+			}
+		}
+		for len(s) > 0 && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
+	}
+	return s == ""
+}
@@ -0,0 +1,270 @@
+package jsonresponse
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fastEmbedded struct {
+	ID      int    `json:"id"`
+	Ignored string `json:"-"`
+}
+
+type fastText string
+
+func (t fastText) MarshalText() ([]byte, error) {
+	return []byte("text:" + string(t)), nil
+}
+
+type fastPtrMarshaler struct {
+	Value int
+}
+
+func (m *fastPtrMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(` { "value" : %d, "html": "<b>" } `, m.Value)), nil
+}
+
+type fastNode struct {
+	Name     string      `json:"name"`
+	Children []*fastNode `json:"children,omitempty"`
+}
+
+type fastValue struct {
+	fastEmbedded
+	Name       string                 `json:"name"`
+	HTML       string                 `json:"html"`
+	Count      int64                  `json:"count,string"`
+	Ratio      float32                `json:"ratio"`
+	Big        float64                `json:"big"`
+	Small      float64                `json:"small"`
+	Unsigned   uint8                  `json:"unsigned"`
+	Flag       bool                   `json:"flag,omitempty"`
+	Quoted     string                 `json:",string"`
+	Optional   *string                `json:"optional,omitempty"`
+	Pointer    *int                   `json:"pointer"`
+	Bytes      []byte                 `json:"bytes"`
+	Nil        []int                  `json:"nil"`
+	Empty      []string               `json:"empty"`
+	Array      [2]bool                `json:"array"`
+	Map        map[string]interface{} `json:"map"`
+	IntKeys    map[int]string         `json:"int_keys"`
+	TextKeys   map[fastText]int       `json:"text_keys"`
+	Text       fastText               `json:"text"`
+	Time       time.Time              `json:"time"`
+	Marshaler  fastPtrMarshaler       `json:"marshaler"`
+	Raw        json.RawMessage        `json:"raw"`
+	Number     json.Number            `json:"number"`
+	Any        interface{}            `json:"any"`
+	Tree       *fastNode              `json:"tree"`
+	Zero       time.Time              `json:"zero,omitzero"`
+	unexported int
+}
+
+func newFastValue() fastValue {
+	name := "optional"
+	pointer := 42
+	return fastValue{
+		fastEmbedded: fastEmbedded{ID: 1, Ignored: "ignored"},
+		Name:         "name \"quoted\" \n\t\u2028 \xff é",
+		HTML:         "<script>alert('&')</script>",
+		Count:        -12,
+		Ratio:        0.1,
+		Big:          1e21,
+		Small:        0.000000123,
+		Unsigned:     255,
+		Quoted:       "a<b",
+		Optional:     &name,
+		Pointer:      &pointer,
+		Bytes:        []byte("bytes\x00"),
+		Empty:        []string{},
+		Array:        [2]bool{true, false},
+		Map:          map[string]interface{}{"b": 1.5, "a": []interface{}{"x", nil, map[string]interface{}{}}},
+		IntKeys:      map[int]string{10: "ten", -1: "minus one", 2: "two"},
+		TextKeys:     map[fastText]int{"b": 2, "a": 1},
+		Text:         "value",
+		Time:         time.Date(2017, 5, 1, 12, 30, 0, 0, time.UTC),
+		Marshaler:    fastPtrMarshaler{Value: 7},
+		Raw:          json.RawMessage(`[1, 2,  3]`),
+		Number:       "1.5e10",
+		Any:          &fastPtrMarshaler{Value: 8},
+		Tree:         &fastNode{Name: "root", Children: []*fastNode{{Name: "child"}}},
+		unexported:   1,
+	}
+}
+
+func encodeWithJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func encodeWithFast(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := encodeFast(&buf, v, false, current())
+	return buf.Bytes(), err
+}
+
+func TestFastEncoderMatchesEncodingJSON(t *testing.T) {
+	value := newFastValue()
+	for _, v := range []interface{}{
+		nil,
+		true,
+		"string",
+		-1.5,
+		float32(3.4e38),
+		1e-7,
+		[]interface{}{},
+		map[string]int(nil),
+		value,
+		&value,
+		[]fastValue{value, {}},
+		map[string]fastValue{"first": value},
+		[]byte(nil),
+		&fastPtrMarshaler{Value: 1},
+		[]fastPtrMarshaler{{Value: 1}},
+		map[string]fastPtrMarshaler{"a": {Value: 1}},
+		map[string][1]fastPtrMarshaler{"a": {{Value: 1}}},
+		map[string]string{"b": "2", "a": "1"},
+	} {
+		expected, expectedErr := encodeWithJSON(v)
+		got, err := encodeWithFast(v)
+		if expectedErr != nil || err != nil {
+			fmt.Printf("Unexpected errors %v and %v encoding %#v\n", expectedErr, err, v)
+			t.Fail()
+			continue
+		}
+		if !bytes.Equal(expected, got) {
+			fmt.Printf("Expected %s\nbut got  %s\n", expected, got)
+			t.Fail()
+		}
+	}
+}
+
+func TestFastEncoderIndent(t *testing.T) {
+	value := newFastValue()
+	var expected, got bytes.Buffer
+	enc := json.NewEncoder(&expected)
+	enc.SetIndent("", "\t")
+	enc.Encode(value)
+	encodeFast(&got, value, true, current())
+	if expected.String() != got.String() {
+		fmt.Printf("Expected %s\nbut got  %s\n", expected.String(), got.String())
+		t.Fail()
+	}
+}
+
+func TestFastEncoderErrors(t *testing.T) {
+	for _, v := range []interface{}{
+		math.NaN(),
+		map[string]interface{}{"inf": math.Inf(1)},
+		make(chan int),
+		struct{ F func() }{},
+		json.Number("not a number"),
+	} {
+		if _, err := encodeWithFast(v); err == nil {
+			fmt.Printf("Expected error encoding %#v\n", v)
+			t.Fail()
+		}
+	}
+
+	cycle := &fastNode{Name: "cycle"}
+	cycle.Children = []*fastNode{cycle}
+	if _, err := encodeWithFast(cycle); err == nil {
+		fmt.Println("Expected error encoding cycle.")
+		t.Fail()
+	}
+}
+
+func TestFastEncoderMatchesDefaultEncoderWithFilters(t *testing.T) {
+	SetRedactedKeys("*token*")
+	defer SetRedactedKeys()
+	defer SetFastEncoder(false)
+
+	type account struct {
+		ID      int                    `json:"id"`
+		Email   string                 `json:"email" jsonresponse:"view=admin,owner"`
+		Notes   string                 `json:"notes" jsonresponse:"view=admin"`
+		Secret  string                 `json:"secret" jsonresponse:"redact"`
+		Owner   *redactedUser          `json:"owner"`
+		Details map[string]interface{} `json:"details"`
+	}
+	data := []account{{
+		ID:      1,
+		Email:   "foo@example.com",
+		Notes:   "notes",
+		Secret:  "secret",
+		Owner:   &redactedUser{Name: "foo", PasswordHash: "hash"},
+		Details: map[string]interface{}{"api_token": "abc", "color": "red"},
+	}}
+
+	for _, c := range []struct {
+		view  string
+		query string
+		mode  RedactionMode
+	}{
+		{"", "", RedactReplace},
+		{"owner", "", RedactReplace},
+		{"admin", "", RedactDrop},
+		{"", "?fields=id,owner.name,details.color", RedactReplace},
+		{"owner", "?fields=email,secret", RedactDrop},
+		{"owner", "?fields=notes", RedactReplace},
+	} {
+		SetRedactionMode(c.mode)
+		var bodies [2]string
+		for i, fast := range []bool{false, true} {
+			SetFastEncoder(fast)
+			recorder := httptest.NewRecorder()
+			New(data).View(c.view).OKFor(recorder, httptest.NewRequest("GET", "/"+c.query, nil))
+			bodies[i] = recorder.Body.String()
+		}
+		if bodies[0] != bodies[1] {
+			fmt.Printf("View %q, query %q: expected %s\nbut got  %s\n", c.view, c.query, bodies[0], bodies[1])
+			t.Fail()
+		}
+	}
+	SetRedactionMode(RedactReplace)
+}
+
+func FuzzFastEncoder(f *testing.F) {
+	f.Add("name", int64(1), 1.5, []byte("bytes"), true)
+	f.Add("<&>\u2028\xff\"\\", int64(-1<<63), 1e21, []byte(nil), false)
+	f.Add("", int64(0), -0.000001, []byte{}, true)
+	f.Fuzz(func(t *testing.T, s string, i int64, fl float64, b []byte, flag bool) {
+		if math.IsNaN(fl) || math.IsInf(fl, 0) {
+			return
+		}
+		v := map[string]interface{}{
+			s: fastValue{
+				Name:     s,
+				Count:    i,
+				Ratio:    float32(fl),
+				Big:      fl,
+				Flag:     flag,
+				Quoted:   s,
+				Bytes:    b,
+				Map:      map[string]interface{}{s: s, "number": fl},
+				IntKeys:  map[int]string{int(i): s},
+				TextKeys: map[fastText]int{fastText(s): int(i)},
+				Text:     fastText(s),
+				Raw:      json.RawMessage(`{"s":` + string(appendString(nil, s)) + `}`),
+				Any:      []interface{}{s, i, fl, flag},
+			},
+		}
+		if math.IsInf(float64(float32(fl)), 0) {
+			return
+		}
+		expected, expectedErr := encodeWithJSON(v)
+		got, err := encodeWithFast(v)
+		if (expectedErr == nil) != (err == nil) {
+			t.Fatalf("errors differ: %v and %v", expectedErr, err)
+		}
+		if !bytes.Equal(expected, got) {
+			t.Fatalf("expected %s\nbut got  %s", expected, got)
+		}
+	})
+}
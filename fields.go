@@ -0,0 +1,303 @@
+package jsonresponse
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// field is single field of struct as it is seen by JSON encoder. Resolution
+// of fields follows same rules as encoding/json, including promotion of
+// fields of embedded structs.
+type field struct {
+	name      string
+	tagged    bool
+	index     []int
+	typ       reflect.Type
+	omitEmpty bool
+	omitZero  bool
+	quoted    bool
+
+	// options from jsonresponse tag
+	redact bool
+	views  []string
+}
+
+// visibleIn returns true if field is visible in provided view. Fields without
+// views are visible in all views, and fields with views are hidden when no
+// view is selected.
+func (f field) visibleIn(view string) bool {
+	if len(f.views) == 0 {
+		return true
+	}
+	for _, v := range f.views {
+		if v == view {
+			return true
+		}
+	}
+	return false
+}
+
+var fieldCache sync.Map // map[fieldsKey][]field
+
+type fieldsKey struct {
+	t      reflect.Type
+	naming string
+}
+
+// cachedFields returns fields of struct type named with provided naming,
+// computing them only on first use.
+func cachedFields(t reflect.Type, naming keyNaming) []field {
+	key := fieldsKey{t, naming.id}
+	if f, ok := fieldCache.Load(key); ok {
+		return f.([]field)
+	}
+	f, _ := fieldCache.LoadOrStore(key, renameFields(typeFields(t), naming))
+	return f.([]field)
+}
+
+// renameFields writes names of fields that are not set in json tag in casing
+// of naming. Fields whose new name is same as name set in tag of other field
+// are removed, since names from tags always win.
+func renameFields(fields []field, naming keyNaming) []field {
+	if naming.casing == KeepCase {
+		return fields
+	}
+	tagged := map[string]bool{}
+	for _, f := range fields {
+		if f.tagged {
+			tagged[f.name] = true
+		}
+	}
+	renamed := make([]field, 0, len(fields))
+	for _, f := range fields {
+		if !f.tagged {
+			f.name = naming.rename(f.name)
+			if tagged[f.name] {
+				continue
+			}
+		}
+		renamed = append(renamed, f)
+	}
+	return renamed
+}
+
+// typeFields returns fields that should be encoded for provided struct type.
+func typeFields(t reflect.Type) []field {
+	current := []field{}
+	next := []field{{typ: t}}
+
+	count := map[reflect.Type]int{}
+	nextCount := map[reflect.Type]int{}
+	visited := map[reflect.Type]bool{}
+
+	var fields []field
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
+
+		for _, f := range current {
+			if visited[f.typ] {
+				continue
+			}
+			visited[f.typ] = true
+
+			for i := 0; i < f.typ.NumField(); i++ {
+				sf := f.typ.Field(i)
+				if sf.Anonymous {
+					ft := sf.Type
+					if ft.Kind() == reflect.Ptr {
+						ft = ft.Elem()
+					}
+					if !sf.IsExported() && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !sf.IsExported() {
+					continue
+				}
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, opts := parseTag(tag)
+				if !isValidTag(name) {
+					name = ""
+				}
+				index := make([]int, len(f.index)+1)
+				copy(index, f.index)
+				index[len(f.index)] = i
+
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+
+				quoted := false
+				if opts.contains("string") {
+					switch ft.Kind() {
+					case reflect.Bool,
+						reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+						reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+						reflect.Float32, reflect.Float64,
+						reflect.String:
+						quoted = true
+					}
+				}
+
+				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+					responseOpts := responseTag(sf.Tag.Get("jsonresponse"))
+					tagged := name != ""
+					if name == "" {
+						name = sf.Name
+					}
+					fields = append(fields, field{
+						name:      name,
+						tagged:    tagged,
+						index:     index,
+						typ:       ft,
+						omitEmpty: opts.contains("omitempty"),
+						omitZero:  opts.contains("omitzero"),
+						quoted:    quoted,
+						redact:    responseOpts.contains("redact"),
+						views:     responseOpts.views(),
+					})
+					if count[f.typ] > 1 {
+						// annihilation of fields with same name on same level
+						fields = append(fields, fields[len(fields)-1])
+					}
+					continue
+				}
+
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, field{name: ft.Name(), index: index, typ: ft})
+				}
+			}
+		}
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		x := fields
+		if x[i].name != x[j].name {
+			return x[i].name < x[j].name
+		}
+		if len(x[i].index) != len(x[j].index) {
+			return len(x[i].index) < len(x[j].index)
+		}
+		if x[i].tagged != x[j].tagged {
+			return x[i].tagged
+		}
+		return indexLess(x[i].index, x[j].index)
+	})
+
+	out := fields[:0]
+	for advance, i := 0, 0; i < len(fields); i += advance {
+		fi := fields[i]
+		for advance = 1; i+advance < len(fields); advance++ {
+			if fields[i+advance].name != fi.name {
+				break
+			}
+		}
+		if advance == 1 {
+			out = append(out, fi)
+			continue
+		}
+		if dominant, ok := dominantField(fields[i : i+advance]); ok {
+			out = append(out, dominant)
+		}
+	}
+
+	fields = out
+	sort.Slice(fields, func(i, j int) bool {
+		return indexLess(fields[i].index, fields[j].index)
+	})
+	return fields
+}
+
+// dominantField returns field that wins among fields with same name, if any.
+func dominantField(fields []field) (field, bool) {
+	if len(fields) > 1 && len(fields[0].index) == len(fields[1].index) && fields[0].tagged == fields[1].tagged {
+		return field{}, false
+	}
+	return fields[0], true
+}
+
+func indexLess(a, b []int) bool {
+	for k, v := range a {
+		if k >= len(b) {
+			return false
+		}
+		if v != b[k] {
+			return v < b[k]
+		}
+	}
+	return len(a) < len(b)
+}
+
+type tagOptions string
+
+// responseTag returns options from jsonresponse struct tag, e.g.
+// `jsonresponse:"redact"` or `jsonresponse:"view=admin,owner"`.
+func responseTag(tag string) tagOptions {
+	return tagOptions(tag)
+}
+
+// views returns list of views from "view=" option of jsonresponse tag. All
+// options after it that are not known options are names of views.
+func (o tagOptions) views() []string {
+	var views []string
+	inViews := false
+	for _, opt := range strings.Split(string(o), ",") {
+		opt = strings.TrimSpace(opt)
+		switch {
+		case strings.HasPrefix(opt, "view="):
+			inViews = true
+			opt = strings.TrimPrefix(opt, "view=")
+		case opt == "redact" || strings.Contains(opt, "="):
+			inViews = false
+			continue
+		}
+		if inViews && opt != "" {
+			views = append(views, opt)
+		}
+	}
+	return views
+}
+
+func parseTag(tag string) (string, tagOptions) {
+	if i := strings.IndexByte(tag, ','); i >= 0 {
+		return tag[:i], tagOptions(tag[i+1:])
+	}
+	return tag, ""
+}
+
+func (o tagOptions) contains(name string) bool {
+	s := string(o)
+	for s != "" {
+		var next string
+		if i := strings.IndexByte(s, ','); i >= 0 {
+			s, next = s[:i], s[i+1:]
+		}
+		if s == name {
+			return true
+		}
+		s = next
+	}
+	return false
+}
+
+func isValidTag(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			return false
+		}
+	}
+	return true
+}
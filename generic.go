@@ -1,25 +1,39 @@
 package jsonresponse
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
 	"net/http"
 )
 
 // MessageResponse is default wrapper structure for JSON http response.
 type MessageResponse struct {
-	Code    int    `json:"code,omitempty"`
-	Message string `json:"message,omitempty"`
+	Code      int    `json:"code,omitempty"`
+	Message   string `json:"message,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // Respond serializes provided response to JSON and writes it to provided writer
 // with status code.
 func Respond(w http.ResponseWriter, statusCode int, response interface{}) {
+	respond(context.Background(), w, statusCode, response, true)
+}
+
+// respond is same as Respond, except that limit of buffered response is
+// applied only if limited is true. Hooks are invoked with provided context
+// only if limited is true, since otherwise response replaces one that is
+// already reported to hooks.
+func respond(ctx context.Context, w http.ResponseWriter, statusCode int, response interface{}, limited bool) {
+	respondWith(ctx, w, statusCode, response, limited, current())
+}
+
+// respondWith is same as respond, with provided snapshot of configuration.
+func respondWith(ctx context.Context, w http.ResponseWriter, statusCode int, response interface{}, limited bool, s *settings) {
 	if response == nil {
 		response = &MessageResponse{}
 	} else if r, ok := response.(MessageResponse); ok {
 		response = &r
 	}
+	response = withRequestID(response, requestIDOf(w, s.requestIDHeader))
 	if r, ok := response.(*MessageResponse); ok {
 		if r.Code == 0 {
 			r.Code = statusCode
@@ -28,15 +42,23 @@ func Respond(w http.ResponseWriter, statusCode int, response interface{}) {
 			r.Message = http.StatusText(statusCode)
 		}
 	}
-	b, err := json.Marshal(response)
-	if err != nil {
-		panic(err)
+	data := response
+	if s.redaction.redactsKeys() {
+		response = &filtered{data: response, redactKeys: true, s: s}
 	}
-	if defaultContentTypeHeader != "" {
-		w.Header().Set("Content-Type", defaultContentTypeHeader)
+	response = applyNumberPolicy(response, s.numberPolicy, s)
+	// generic responses are never indented
+	c := *s
+	c.indent = false
+	if !limited {
+		w.Header().Del("Content-Length")
+		c.bufferLimit, c.oversizeMode = 0, OversizeError
+		if err := writeBodyWithLimit(w, statusCode, response, nil, &c); err != nil {
+			panic(err)
+		}
+		return
 	}
-	w.WriteHeader(statusCode)
-	fmt.Fprint(w, string(b)+"\n")
+	writeBody(ctx, w, statusCode, data, response, nil, &c)
 }
 
 // 1xx
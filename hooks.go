@@ -0,0 +1,25 @@
+package jsonresponse
+
+import (
+	"context"
+	"time"
+)
+
+// Hooks are invoked for every response that is sent, also for responses
+// without body, so it can be used for logging, metrics or tracing. Hooks are
+// set via SetHooks.
+type Hooks interface {
+	// BeforeEncode is called before body of response is encoded, after
+	// transformer was applied. Data is data of response as passed to it, not
+	// transformed body, and it is nil for responses without body.
+	// Context is context of request for responses sent for request (e.g.
+	// via ResponseFor), otherwise it is background context.
+	BeforeEncode(ctx context.Context, status int, data interface{})
+	// AfterWrite is called after response is written with status code
+	// and number of bytes of body that were sent, and time spent encoding
+	// and writing it. Error is set if data could not be encoded or signed, in
+	// which case status is 500 if response exceeded buffer limit (see
+	// SetBufferLimit) or could not be signed (see SetSigning and JWS),
+	// otherwise encoding panics after hooks are called.
+	AfterWrite(ctx context.Context, status int, bytes int, duration time.Duration, err error)
+}
@@ -0,0 +1,91 @@
+package jsonresponse
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type contextKey string
+
+type recordingHooks struct {
+	calls []string
+}
+
+func (h *recordingHooks) BeforeEncode(ctx context.Context, status int, data interface{}) {
+	h.calls = append(h.calls, fmt.Sprintf("before %d %v %T", status, ctx.Value(contextKey("id")), data))
+}
+
+func (h *recordingHooks) AfterWrite(ctx context.Context, status int, bytes int, duration time.Duration, err error) {
+	h.calls = append(h.calls, fmt.Sprintf("after %d %v %d %v", status, ctx.Value(contextKey("id")), bytes, err))
+}
+
+func TestHooks(t *testing.T) {
+	hooks := &recordingHooks{}
+	SetHooks(hooks)
+	defer SetHooks()
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), contextKey("id"), "abc"))
+	New("value").ResponseFor(httptest.NewRecorder(), req, http.StatusCreated)
+	NotFound(httptest.NewRecorder(), nil)
+
+	expected := []string{
+		"before 201 abc string",
+		"after 201 abc 17 <nil>",
+		"before 404 <nil> *jsonresponse.MessageResponse",
+		"after 404 <nil> 35 <nil>",
+	}
+	if strings.Join(hooks.calls, "\n") != strings.Join(expected, "\n") {
+		fmt.Printf("Expected calls\n%s\nbut got\n%s\n", strings.Join(expected, "\n"), strings.Join(hooks.calls, "\n"))
+		t.Fail()
+	}
+}
+
+func TestHooksEncodeFailure(t *testing.T) {
+	hooks := &recordingHooks{}
+	SetHooks(hooks)
+	defer SetHooks()
+	SetBufferLimit(10, OversizeError)
+	defer SetBufferLimit(0, OversizeError)
+
+	recorder := httptest.NewRecorder()
+	New(strings.Repeat("a", 100)).OK(recorder)
+	if len(hooks.calls) != 2 || hooks.calls[1] != "after 500 <nil> 47 "+errResponseTooLarge.Error() {
+		fmt.Printf("Expected failure reported to hooks, got %v\n", hooks.calls)
+		t.Fail()
+	}
+
+	hooks.calls = nil
+	func() {
+		defer func() { recover() }()
+		New(func() {}).OK(httptest.NewRecorder())
+	}()
+	if len(hooks.calls) != 2 || !strings.HasPrefix(hooks.calls[1], "after 200 <nil> 0 json: unsupported type") {
+		fmt.Printf("Expected encode error reported to hooks, got %v\n", hooks.calls)
+		t.Fail()
+	}
+}
+
+func TestHooksReceiveDataOfResponse(t *testing.T) {
+	hooks := &recordingHooks{}
+	SetHooks(hooks)
+	defer SetHooks()
+
+	data := map[string]interface{}{"id": 1}
+	New(data).Canonical().OK(httptest.NewRecorder())
+	New(data).JWS(JWSOptions{Key: HS256Key([]byte("secret"))}).OK(httptest.NewRecorder())
+	New(nil).NoContent(httptest.NewRecorder())
+
+	expected := []string{"before 200 <nil> map[string]interface {}", "before 200 <nil> map[string]interface {}", "before 204 <nil> <nil>"}
+	for i, call := range expected {
+		if i*2 >= len(hooks.calls) || hooks.calls[i*2] != call {
+			fmt.Printf("Expected %s, got calls %v\n", call, hooks.calls)
+			t.Fail()
+		}
+	}
+}
@@ -21,12 +21,19 @@
 //         obj := ...
 //	       jsonResponse.New(obj).Header("X-Custom-Header", "this is cool").OK(w)
 //     }
+//
+// Example of usage with request passed to transformer (see SetRequestTransformer):
+//
+//     func someHandler(w http.ResponseWriter, r *Request) {
+//         // create some object to return
+//         obj := ...
+//	       jsonResponse.New(obj).OKFor(w, r)
+//     }
 
 package jsonresponse
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
 	"net/http"
 )
 
@@ -36,20 +43,30 @@ type Response struct {
 	Data    interface{}
 	Headers map[string]string
 	Excuse  string
-}
-
-func serializeToString(data interface{}) (s string) {
-	var b []byte
-	var err error
-	if indent {
-		b, err = json.MarshalIndent(data, "", "\t")
-	} else {
-		b, err = json.Marshal(data)
-	}
-	if err != nil {
-		panic(err)
-	}
-	return string(b)
+	// Metadata is included in "meta" object of envelope by default transformer
+	// and MessageCode transformers, if it is not empty (see Meta and SetMetaField).
+	Metadata map[string]interface{}
+
+	// page is set for paginated responses, in order to add Link header
+	// when response is sent for request.
+	page *PageInfo
+	// view in which data is rendered, see View.
+	view string
+	// selection are fields of data requested for response, see ResponseFor.
+	selection fieldSelection
+	// transformer provided by data, see TransformerProvider.
+	transformer RequestTransformer
+	// timings are server timing metrics, see Timing.
+	timings []Timing
+	// numbers is policy of encoding numbers, see Numbers.
+	numbers *NumberPolicy
+	// canonical is set if body is encoded in canonical form, see Canonical.
+	canonical bool
+	// jws are options of JWS of body, see JWS.
+	jws *JWSOptions
+	// settings is snapshot of configuration with which response is written,
+	// it is set when writing starts.
+	settings *settings
 }
 
 // New creates response object with provided data and returns it.
@@ -67,37 +84,87 @@ func Empty() (r Response) {
 // Response transforms body, sets headers and writes encoded body (to JSON) to
 // provided writer.
 func (r Response) Response(w http.ResponseWriter, httpCode int) {
+	r.ResponseFor(w, nil, httpCode)
+}
+
+// ResponseFor is same as Response, except that request for which response
+// is sent is passed to transformer. Data is rendered in view from request
+// context, unless response has its own (see View). If request contains fields
+// query parameter (see SetFieldsParameter), data is pruned to requested fields,
+// or FieldsError is sent with status 400 if some of them do not exist. Data is
+// filtered in result of transformer, so transformer receives it unchanged and
+// fields it adds to data are kept.
+func (r Response) ResponseFor(w http.ResponseWriter, req *http.Request, httpCode int) {
+	r.settings = current()
+	if p, ok := r.Data.(TransformerProvider); ok {
+		r.transformer = p.Transformer()
+	}
+	if r.Data != nil {
+		r.view = r.viewFor(req)
+		selection, fieldsErr := selectFields(req, r.Data, r.view, r.settings)
+		if fieldsErr != nil {
+			e := New(fieldsErr)
+			e.settings = r.settings
+			e.write(w, req, http.StatusBadRequest)
+			return
+		}
+		r.selection = selection
+	}
+	r.write(w, req, httpCode)
+}
+
+// write transforms body, sets headers and writes encoded body to provided
+// writer, with snapshot of configuration of response.
+func (r Response) write(w http.ResponseWriter, req *http.Request, httpCode int) {
 	var headers map[string]string
 	var body interface{}
-	if transformer != nil && r.Data != nil {
-		headers, body = transformer(r, httpCode)
+	s := r.settings
+	timings := r.timingsFor(req)
+	r.Metadata = r.metaFor(req, timings, s)
+	entry := transformerEntry{transform: r.transformer}
+	if entry.transform != nil {
+		entry.describer, _ = r.Data.(DataPathDescriber)
+	} else {
+		entry = s.transformerFor(httpCode)
+	}
+	if entry.transform != nil && r.Data != nil {
+		headers, body = entry.transform(req, r, httpCode)
+		body = r.filterData(body, entry.describer, s)
 	} else {
 		headers = map[string]string{}
-		body = r.Data
+		body = r.filterData(r.Data, Envelope{}, s)
 	}
-
-	// if we have headers for this response, include it (and override transformer headers)
-	for k, v := range r.Headers {
-		headers[k] = v
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	body = applyNumberPolicy(body, r.numberPolicyFor(s), s)
+	if r.canonical && body != nil {
+		body = canonical{body}
+	}
+	if r.jws != nil && body != nil {
+		body = jwsBody{body, *r.jws}
 	}
 
-	// set headers to response writer
-	responseHeaders := w.Header()
-	for k, v := range headers {
-		responseHeaders.Set(k, v)
+	if r.page != nil && req != nil {
+		if link := r.page.link(req.URL); link != "" {
+			headers["Link"] = link
+		}
 	}
 
-	// if Content-Type is not already included - add it here
-	if _, ok := headers["Content-Type"]; !ok {
-		responseHeaders.Set("Content-Type", defaultContentTypeHeader)
+	if value := serverTiming(timings); value != "" {
+		headers["Server-Timing"] = value
 	}
 
-	// write headers
-	w.WriteHeader(httpCode)
+	// if we have headers for this response, include it (and override transformer headers)
+	for k, v := range r.Headers {
+		headers[k] = v
+	}
 
-	if body != nil {
-		fmt.Fprint(w, serializeToString(body)+"\n")
+	ctx := context.Background()
+	if req != nil {
+		ctx = req.Context()
 	}
+	writeBody(ctx, w, httpCode, r.Data, body, headers, s)
 }
 
 // Header adds header to response.
@@ -302,3 +302,124 @@ func TestIndent(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestRequestTransformerReceivesRequest(t *testing.T) {
+	var received *http.Request
+	SetRequestTransformer(func(req *http.Request, r Response, httpCode int) (headers map[string]string, response interface{}) {
+		received = req
+		return map[string]string{}, map[string]interface{}{"path": req.URL.Path, "data": r.Data}
+	})
+	defer ResetTransformer()
+
+	request := httptest.NewRequest("GET", "/users/42", nil)
+	recorder := httptest.NewRecorder()
+	New("foo").CreatedFor(recorder, request)
+	if received != request {
+		fmt.Println("Request transformer did not receive request.")
+		t.Fail()
+	}
+	if recorder.Code != http.StatusCreated {
+		fmt.Printf("HTTP code did not match, got %d, expected: %d\n", recorder.Code, http.StatusCreated)
+		t.Fail()
+	}
+	unmarshaled := make(map[string]interface{})
+	if err := json.Unmarshal(recorder.Body.Bytes(), &unmarshaled); err != nil {
+		fmt.Println("Failed do unmarshal response!: ", err)
+		t.Fail()
+	}
+	if unmarshaled["path"] != "/users/42" {
+		fmt.Println("Request path not included in response.")
+		t.Fail()
+	}
+}
+
+func TestResponseTransformerAdapted(t *testing.T) {
+	isCalled := false
+	SetTransformer(func(r Response, httpCode int) (headers map[string]string, response interface{}) {
+		isCalled = true
+		return nil, r.Data
+	})
+	defer ResetTransformer()
+
+	recorder := httptest.NewRecorder()
+	New("foo").OKFor(recorder, httptest.NewRequest("GET", "/", nil))
+	if !isCalled {
+		fmt.Println("Response transformer not called for request aware helper.")
+		t.Fail()
+	}
+	if strings.TrimSpace(recorder.Body.String()) != `"foo"` {
+		fmt.Println("Response transformer result not written.")
+		t.Fail()
+	}
+}
+
+func TestTransformerForStatusClassAndCode(t *testing.T) {
+	flat := func(r Response, httpCode int) (headers map[string]string, response interface{}) {
+		return map[string]string{}, map[string]interface{}{"error": r.Data}
+	}
+	notFound := func(r Response, httpCode int) (headers map[string]string, response interface{}) {
+		return map[string]string{}, map[string]interface{}{"missing": r.Data}
+	}
+	SetTransformerFor(StatusClassClientError, flat)
+	SetTransformerForCode(http.StatusNotFound, notFound)
+
+	for code, expected := range map[int]map[string]interface{}{
+		http.StatusOK:         map[string]interface{}{"data": "foo"},
+		http.StatusBadRequest: map[string]interface{}{"error": "foo"},
+		http.StatusNotFound:   map[string]interface{}{"missing": "foo"},
+	} {
+		recorder := httptest.NewRecorder()
+		New("foo").Response(recorder, code)
+		unmarshaled := make(map[string]interface{})
+		if err := json.Unmarshal(recorder.Body.Bytes(), &unmarshaled); err != nil {
+			fmt.Println("Failed do unmarshal response!: ", err)
+			t.Fail()
+		}
+		if !reflect.DeepEqual(unmarshaled, expected) {
+			fmt.Printf("Expected %#v\nbut got  %#v\n", expected, unmarshaled)
+			t.Fail()
+		}
+	}
+
+	ResetTransformer()
+	recorder := httptest.NewRecorder()
+	New("foo").BadRequest(recorder)
+	unmarshaled := make(map[string]interface{})
+	json.Unmarshal(recorder.Body.Bytes(), &unmarshaled)
+	if _, ok := unmarshaled["data"]; !ok {
+		fmt.Println("ResetTransformer did not remove status class transformer.")
+		t.Fail()
+	}
+}
+
+func TestResponseUsesSnapshotOfConfiguration(t *testing.T) {
+	SetIndent(false)
+	type user struct {
+		UserID   int
+		UserName string
+	}
+	for _, fast := range []bool{false, true} {
+		SetFastEncoder(fast)
+		// configuration changed while response is written does not affect it
+		SetRequestTransformer(func(req *http.Request, r Response, httpCode int) (map[string]string, interface{}) {
+			SetKeyCasing(SnakeCase)
+			SetMetaField("info")
+			SetRedactedKeys("UserID")
+			return AdaptTransformer(defaultTransformer)(req, r, httpCode)
+		})
+
+		recorder := httptest.NewRecorder()
+		New(user{1, "a"}).Meta("page", 1).OKFor(recorder, httptest.NewRequest("GET", "/?fields=UserID", nil))
+		expected := `{"data":{"UserID":1},"meta":{"page":1}}` + "\n"
+		if recorder.Body.String() != expected {
+			fmt.Printf("Fast encoder %v: expected %s but got %s", fast, expected, recorder.Body.String())
+			t.Fail()
+		}
+
+		ResetTransformer()
+		SetKeyCasing(KeepCase)
+		SetMetaField("meta")
+		SetRedactedKeys()
+	}
+	SetFastEncoder(false)
+}
@@ -0,0 +1,159 @@
+package jsonresponsetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// MaskedValue replaces masked values in golden files.
+const MaskedValue = "<masked>"
+
+// UpdateFlag is name of flag of test binary which rewrites golden files
+// instead of comparing responses with them. Flag is not registered when this
+// package is imported, since test package can define flag with same name
+// itself, it is registered by RegisterUpdateFlag.
+const UpdateFlag = "update"
+
+// UpdateEnv is name of environment variable which, when set to "1" or
+// "true", rewrites golden files same as UpdateFlag.
+const UpdateEnv = "UPDATE_GOLDEN"
+
+// RegisterUpdateFlag registers UpdateFlag, unless test package already
+// defines it. It has to be called before flags are parsed, e.g. in init
+// function of test package.
+func RegisterUpdateFlag() {
+	if flag.Lookup(UpdateFlag) == nil {
+		flag.Bool(UpdateFlag, false, "rewrite golden files with actual responses")
+	}
+}
+
+// updateGolden returns true if UpdateFlag is set, whoever registered it, or
+// if UpdateEnv is set.
+func updateGolden() bool {
+	if f := flag.Lookup(UpdateFlag); f != nil {
+		if g, ok := f.Value.(flag.Getter); ok {
+			if update, _ := g.Get().(bool); update {
+				return true
+			}
+		}
+	}
+	switch os.Getenv(UpdateEnv) {
+	case "1", "true":
+		return true
+	}
+	return false
+}
+
+// Golden compares response recorded by rec with golden file on provided path.
+// Response is normalized first: it contains status line, headers sorted
+// by name and body as indented JSON with sorted keys. Values that change
+// between runs can be masked, masks starting with $ are paths of JSON values
+// (e.g. "$.data[*].created_at") and other masks are names of headers (e.g.
+// "X-Request-Id"). When JSON values are masked, Content-Length is masked too.
+//
+// When tests are run with -update flag (see RegisterUpdateFlag) or with
+// UPDATE_GOLDEN environment variable set to 1, golden file is rewritten with
+// actual response instead, e.g. go test ./... -update.
+func Golden(t testing.TB, rec *httptest.ResponseRecorder, path string, masks ...string) {
+	t.Helper()
+	actual, err := snapshot(rec, masks)
+	if err != nil {
+		t.Errorf("golden file %s: %v", path, err)
+		return
+	}
+
+	if updateGolden() {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Errorf("can not create directory for golden file %s: %v", path, err)
+			return
+		}
+		if err := os.WriteFile(path, actual, 0644); err != nil {
+			t.Errorf("can not write golden file %s: %v", path, err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Errorf("can not read golden file %s (run tests with -update or UPDATE_GOLDEN=1 to create it): %v", path, err)
+		return
+	}
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("response does not match golden file %s (-expected +got):\n%s",
+			path, diffLines(strings.Split(string(expected), "\n"), strings.Split(string(actual), "\n")))
+	}
+}
+
+// Golden is same as Golden function for recorded response.
+func (r *Result) Golden(path string, masks ...string) *Result {
+	r.t.Helper()
+	Golden(r.t, r.Recorder, path, masks...)
+	return r
+}
+
+// snapshot returns normalized response with masked values.
+func snapshot(rec *httptest.ResponseRecorder, masks []string) ([]byte, error) {
+	var valueMasks [][]segment
+	headerMasks := map[string]bool{}
+	for _, mask := range masks {
+		if !strings.HasPrefix(mask, "$") {
+			headerMasks[http.CanonicalHeaderKey(mask)] = true
+			continue
+		}
+		segments, err := parsePath(mask)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mask %s: %v", mask, err)
+		}
+		valueMasks = append(valueMasks, segments)
+	}
+	if len(valueMasks) > 0 {
+		headerMasks["Content-Length"] = true
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "HTTP/1.1 %d %s\n", rec.Code, http.StatusText(rec.Code))
+	header := rec.Result().Header
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range header[name] {
+			if headerMasks[name] {
+				value = MaskedValue
+			}
+			fmt.Fprintf(&out, "%s: %s\n", name, value)
+		}
+	}
+	out.WriteString("\n")
+
+	body := rec.Body.Bytes()
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil || decoder.More() {
+		// not JSON, body is kept as it is
+		out.Write(body)
+		return out.Bytes(), nil
+	}
+	for _, segments := range valueMasks {
+		replace(value, segments, MaskedValue)
+	}
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
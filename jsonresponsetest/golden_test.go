@@ -0,0 +1,93 @@
+package jsonresponsetest
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/delicb/jsonresponse"
+)
+
+type event struct {
+	ID      int       `json:"id"`
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+}
+
+var eventsHandler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+	jsonresponse.New([]event{
+		{ID: 1, Name: "<first>", Created: time.Now()},
+		{ID: 2, Name: "second", Created: time.Now()},
+	}).Header("X-Request-Id", time.Now().String()).OKFor(w, req)
+})
+
+// update is flag of test package itself, which Golden uses.
+var update = flag.Bool("update", false, "rewrite golden files")
+
+func init() {
+	// flag is already defined, so this does nothing
+	RegisterUpdateFlag()
+}
+
+func TestGolden(t *testing.T) {
+	Record(t, eventsHandler, httptest.NewRequest("GET", "/events", nil)).
+		Golden(filepath.Join("testdata", "events.golden"), "$.data[*].created", "x-request-id")
+}
+
+func TestGoldenMismatch(t *testing.T) {
+	jsonresponse.SetEnvelope(jsonresponse.MessageCodeTransformer("payload", "code"))
+	defer jsonresponse.ResetTransformer()
+
+	rt := &recordingT{TB: t}
+	Record(rt, eventsHandler, httptest.NewRequest("GET", "/events", nil)).
+		Golden(filepath.Join("testdata", "events.golden"), "$.payload[*].created", "X-Request-Id")
+	if len(rt.errors) != 1 || !strings.Contains(rt.errors[0], `-   "data": [`) || !strings.Contains(rt.errors[0], `+   "payload": [`) {
+		fmt.Printf("Expected diff of envelope, got %v\n", rt.errors)
+		t.Fail()
+	}
+}
+
+func TestGoldenUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "events.golden")
+
+	t.Setenv(UpdateEnv, "1")
+	Record(t, eventsHandler, httptest.NewRequest("GET", "/events", nil)).Golden(path, "$.data[*].created", "X-Request-Id")
+
+	expected, _ := os.ReadFile(filepath.Join("testdata", "events.golden"))
+	got, err := os.ReadFile(path)
+	if err != nil || string(expected) != string(got) {
+		fmt.Printf("Expected golden file\n%s\nbut got (%v)\n%s\n", expected, err, got)
+		t.Fail()
+	}
+}
+
+func TestGoldenUpdateFlag(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.golden")
+
+	*update = true
+	defer func() { *update = false }()
+	Record(t, eventsHandler, httptest.NewRequest("GET", "/events", nil)).Golden(path, "$.data[*].created", "X-Request-Id")
+
+	expected, _ := os.ReadFile(filepath.Join("testdata", "events.golden"))
+	got, err := os.ReadFile(path)
+	if err != nil || string(expected) != string(got) {
+		fmt.Printf("Expected golden file\n%s\nbut got (%v)\n%s\n", expected, err, got)
+		t.Fail()
+	}
+}
+
+func TestGoldenMissingFile(t *testing.T) {
+	rt := &recordingT{TB: t}
+	Record(rt, eventsHandler, httptest.NewRequest("GET", "/events", nil)).
+		Golden(filepath.Join("testdata", "missing.golden"))
+	if len(rt.errors) != 1 || !strings.Contains(rt.errors[0], "-"+UpdateFlag) || !strings.Contains(rt.errors[0], UpdateEnv) {
+		fmt.Printf("Expected hint about -%s and %s, got %v\n", UpdateFlag, UpdateEnv, rt.errors)
+		t.Fail()
+	}
+}
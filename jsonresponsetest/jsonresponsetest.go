@@ -0,0 +1,268 @@
+// Package jsonresponsetest provides helpers for testing HTTP handlers that
+// send responses using jsonresponse.
+//
+// Example:
+//
+//	req := httptest.NewRequest("GET", "/users/42", nil)
+//	jsonresponsetest.Record(t, handler, req).
+//		Status(http.StatusOK).
+//		Header("Content-Type", "application/json; charset=utf-8").
+//		JSONPath("$.data.id", 42).
+//		DataEq(`{"id": 42, "name": "foo"}`)
+//
+// Assertions that target data (Data and DataEq) know where transformer
+// that is currently set places data, if it declares it (like default
+// transformer and transformers set via jsonresponse.SetEnvelope), so tests
+// do not depend on envelope.
+package jsonresponsetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/delicb/jsonresponse"
+)
+
+// Result is recorded response of handler with assertions. Failed assertions
+// are reported to test and do not stop it, so all of them can be chained.
+type Result struct {
+	// Recorder contains recorded response.
+	Recorder *httptest.ResponseRecorder
+
+	t testing.TB
+}
+
+// Record serves request with handler and returns recorded response.
+func Record(t testing.TB, handler http.Handler, req *http.Request) *Result {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return &Result{Recorder: recorder, t: t}
+}
+
+// Body returns body of response.
+func (r *Result) Body() string {
+	return r.Recorder.Body.String()
+}
+
+// Status asserts status code of response.
+func (r *Result) Status(code int) *Result {
+	r.t.Helper()
+	if r.Recorder.Code != code {
+		r.t.Errorf("expected status %d, got %d", code, r.Recorder.Code)
+	}
+	return r
+}
+
+// Header asserts value of response header.
+func (r *Result) Header(key, value string) *Result {
+	r.t.Helper()
+	if got := r.Recorder.Header().Get(key); got != value {
+		r.t.Errorf("expected header %s to be %q, got %q", key, value, got)
+	}
+	return r
+}
+
+// Verified asserts that Content-Digest header matches body of response, and
+// that HTTP message signature of response is valid if keys are provided
+// (see jsonresponse.VerifyResponse).
+func (r *Result) Verified(keys jsonresponse.KeyProvider, opts ...jsonresponse.VerifyOption) *Result {
+	r.t.Helper()
+	if err := jsonresponse.VerifyResponse(r.Recorder.Result(), r.Recorder.Body.Bytes(), keys, opts...); err != nil {
+		r.t.Errorf("expected verified response: %v", err)
+	}
+	return r
+}
+
+// JSONEq asserts that body of response is equal to expected JSON, ignoring
+// formatting and order of keys. Expected value can be JSON in string or
+// []byte, or any value that is encoded to JSON.
+func (r *Result) JSONEq(expected interface{}) *Result {
+	r.t.Helper()
+	body, ok := r.decodeBody()
+	if !ok {
+		return r
+	}
+	r.assertEqual("body", expected, body)
+	return r
+}
+
+// JSONPath asserts value in body of response on provided path, e.g.
+// "$.data.items[0].id". Path "$" refers to whole body.
+func (r *Result) JSONPath(path string, expected interface{}) *Result {
+	r.t.Helper()
+	body, ok := r.decodeBody()
+	if !ok {
+		return r
+	}
+	value, err := lookup(body, path)
+	if err != nil {
+		r.t.Errorf("path %s: %v", path, err)
+		return r
+	}
+	r.assertEqual(path, expected, value)
+	return r
+}
+
+// Envelope decodes whole body of response into dst.
+func (r *Result) Envelope(dst interface{}) *Result {
+	r.t.Helper()
+	if err := json.Unmarshal(r.Recorder.Body.Bytes(), dst); err != nil {
+		r.t.Errorf("can not decode body: %v\n%s", err, r.Body())
+	}
+	return r
+}
+
+// Data decodes data of response, found in envelope produced by transformer
+// used for status code of response, into dst.
+func (r *Result) Data(dst interface{}) *Result {
+	r.t.Helper()
+	data, ok := r.data()
+	if !ok {
+		return r
+	}
+	b, _ := json.Marshal(data)
+	if err := json.Unmarshal(b, dst); err != nil {
+		r.t.Errorf("can not decode data: %v\n%s", err, b)
+	}
+	return r
+}
+
+// DataEq is same as JSONEq, except that it compares only data of response.
+func (r *Result) DataEq(expected interface{}) *Result {
+	r.t.Helper()
+	data, ok := r.data()
+	if !ok {
+		return r
+	}
+	r.assertEqual("data", expected, data)
+	return r
+}
+
+func (r *Result) decodeBody() (interface{}, bool) {
+	r.t.Helper()
+	var body interface{}
+	if err := json.Unmarshal(r.Recorder.Body.Bytes(), &body); err != nil {
+		r.t.Errorf("body is not valid JSON: %v\n%s", err, r.Body())
+		return nil, false
+	}
+	return body, true
+}
+
+func (r *Result) data() (interface{}, bool) {
+	r.t.Helper()
+	body, ok := r.decodeBody()
+	if !ok {
+		return nil, false
+	}
+	path := jsonresponse.DataPath(r.Recorder.Code)
+	if path == nil {
+		r.t.Errorf("transformer for status %d does not declare where it places data, see jsonresponse.Envelope", r.Recorder.Code)
+		return nil, false
+	}
+	data := body
+	for _, key := range path {
+		obj, ok := data.(map[string]interface{})
+		if !ok {
+			r.t.Errorf("data not found in body, expected it under %q\n%s", strings.Join(path, "."), r.Body())
+			return nil, false
+		}
+		if data, ok = obj[key]; !ok {
+			r.t.Errorf("data not found in body, expected it under %q\n%s", strings.Join(path, "."), r.Body())
+			return nil, false
+		}
+	}
+	return data, true
+}
+
+func (r *Result) assertEqual(what string, expected, got interface{}) {
+	r.t.Helper()
+	normalized, err := normalize(expected)
+	if err != nil {
+		r.t.Errorf("invalid expected value for %s: %v", what, err)
+		return
+	}
+	if !reflect.DeepEqual(normalized, got) {
+		r.t.Errorf("%s does not match (-expected +got):\n%s", what, Diff(normalized, got))
+	}
+}
+
+// normalize converts value to same representation that json.Unmarshal
+// produces when decoding into interface{}.
+func normalize(v interface{}) (interface{}, error) {
+	var b []byte
+	switch t := v.(type) {
+	case string:
+		b = []byte(t)
+	case []byte:
+		b = t
+	case json.RawMessage:
+		b = t
+	default:
+		var err error
+		if b, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	var normalized interface{}
+	err := json.Unmarshal(b, &normalized)
+	return normalized, err
+}
+
+// Diff returns line by line difference of indented JSON encodings of expected
+// and got values. Lines only in expected are prefixed with "-", lines only in
+// got with "+".
+func Diff(expected, got interface{}) string {
+	return diffLines(indentedLines(expected), indentedLines(got))
+}
+
+// diffLines returns line by line difference of a and b.
+func diffLines(a, b []string) string {
+	// longest common subsequence of lines
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			fmt.Fprintf(&out, "  %s\n", a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			fmt.Fprintf(&out, "- %s\n", a[i])
+			i++
+		default:
+			fmt.Fprintf(&out, "+ %s\n", b[j])
+			j++
+		}
+	}
+	return out.String()
+}
+
+func indentedLines(v interface{}) []string {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return []string{fmt.Sprintf("%#v", v)}
+	}
+	return strings.Split(string(b), "\n")
+}
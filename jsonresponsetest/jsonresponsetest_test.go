@@ -0,0 +1,144 @@
+package jsonresponsetest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/delicb/jsonresponse"
+)
+
+type user struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+var userHandler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+	jsonresponse.New(user{ID: 42, Name: "foo"}).OKFor(w, req)
+})
+
+// recordingT records failures instead of failing test.
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertionsPass(t *testing.T) {
+	var envelope struct {
+		Data user `json:"data"`
+	}
+	var data user
+	Record(t, userHandler, httptest.NewRequest("GET", "/", nil)).
+		Status(http.StatusOK).
+		Header("Content-Type", "application/json; charset=utf-8").
+		JSONEq(`{"data": {"name": "foo", "id": 42}}`).
+		JSONPath("$.data.id", 42).
+		JSONPath("$.data", map[string]interface{}{"id": 42, "name": "foo"}).
+		Envelope(&envelope).
+		Data(&data).
+		DataEq(user{ID: 42, Name: "foo"})
+
+	if envelope.Data != data || data.ID != 42 {
+		fmt.Printf("Unexpected envelope %v and data %v\n", envelope, data)
+		t.Fail()
+	}
+}
+
+func TestAssertionsFail(t *testing.T) {
+	rt := &recordingT{TB: t}
+	Record(rt, userHandler, httptest.NewRequest("GET", "/", nil)).
+		Status(http.StatusCreated).
+		Header("X-Missing", "value").
+		JSONEq(`{"data": {"id": 43, "name": "foo"}}`).
+		JSONPath("$.data.missing", 1).
+		JSONPath("$.data[0]", 1).
+		DataEq(`{"id": 42}`)
+
+	if len(rt.errors) != 6 {
+		fmt.Printf("Expected 6 failures, got %d: %v\n", len(rt.errors), rt.errors)
+		t.Fail()
+		return
+	}
+	if !strings.Contains(rt.errors[2], `-     "id": 43,`) || !strings.Contains(rt.errors[2], `+     "id": 42,`) {
+		fmt.Printf("Expected readable diff, got:\n%s\n", rt.errors[2])
+		t.Fail()
+	}
+}
+
+func TestDataWithCustomTransformer(t *testing.T) {
+	jsonresponse.SetEnvelope(jsonresponse.MessageCodeTransformer("payload", "code"))
+	defer jsonresponse.ResetTransformer()
+
+	Record(t, userHandler, httptest.NewRequest("GET", "/", nil)).
+		JSONPath("$.code", 200).
+		DataEq(`{"id": 42, "name": "foo"}`)
+}
+
+func TestDataWithUndeclaredPath(t *testing.T) {
+	calls := 0
+	jsonresponse.SetTransformer(func(resp jsonresponse.Response, httpCode int) (map[string]string, interface{}) {
+		calls++
+		return nil, map[string]interface{}{"payload": resp.Data}
+	})
+	defer jsonresponse.ResetTransformer()
+
+	rt := &recordingT{TB: t}
+	Record(rt, userHandler, httptest.NewRequest("GET", "/", nil)).
+		DataEq(`{"id": 42, "name": "foo"}`)
+	if calls != 1 || len(rt.errors) != 1 || !strings.Contains(rt.errors[0], "does not declare") {
+		fmt.Printf("Expected transformer to be called only for response and failure, got %d calls and %v\n", calls, rt.errors)
+		t.Fail()
+	}
+}
+
+func TestLookup(t *testing.T) {
+	value := map[string]interface{}{
+		"items": []interface{}{map[string]interface{}{"id": 1.0}},
+	}
+	for path, expected := range map[string]interface{}{
+		"$":              value,
+		"$.items[0].id":  1.0,
+		"$.items[1]":     nil,
+		"$.items.id":     nil,
+		"items[0]":       nil,
+		"$.items[x]":     nil,
+		"$.items[0].id.": nil,
+	} {
+		got, err := lookup(value, path)
+		if expected == nil {
+			if err == nil {
+				fmt.Printf("Expected error for path %s\n", path)
+				t.Fail()
+			}
+			continue
+		}
+		if err != nil || fmt.Sprint(got) != fmt.Sprint(expected) {
+			fmt.Printf("Path %s: expected %v, got %v (%v)\n", path, expected, got, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestVerified(t *testing.T) {
+	keys := jsonresponse.StaticKey("test", jsonresponse.HMACKey([]byte("secret")))
+	jsonresponse.SetSigning(jsonresponse.SigningOptions{Digest: jsonresponse.DigestSHA256, Keys: keys})
+	defer jsonresponse.SetSigning(jsonresponse.SigningOptions{})
+
+	Record(t, userHandler, httptest.NewRequest("GET", "/", nil)).Verified(keys)
+
+	rt := &recordingT{TB: t}
+	Record(rt, userHandler, httptest.NewRequest("GET", "/", nil)).
+		Verified(jsonresponse.StaticKey("test", jsonresponse.HMACKey([]byte("other"))))
+	if len(rt.errors) != 1 {
+		fmt.Printf("Expected verification with wrong key to fail, got %v\n", rt.errors)
+		t.Fail()
+	}
+}
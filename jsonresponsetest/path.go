@@ -0,0 +1,148 @@
+package jsonresponsetest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// segment is single key or index in path.
+type segment struct {
+	key   string
+	index int
+	// isIndex is set for array indexes, e.g. [0]
+	isIndex bool
+	// wildcard is set for [*], which matches all elements of array
+	wildcard bool
+}
+
+// parsePath parses path like "$.data.items[0].id" to segments.
+func parsePath(path string) ([]segment, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("path has to start with $")
+	}
+	var segments []segment
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("empty key")
+			}
+			segments = append(segments, segment{key: rest[:end]})
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("missing ]")
+			}
+			if rest[1:end] == "*" {
+				segments = append(segments, segment{isIndex: true, wildcard: true})
+			} else {
+				index, err := strconv.Atoi(rest[1:end])
+				if err != nil {
+					return nil, fmt.Errorf("invalid index %q", rest[1:end])
+				}
+				segments = append(segments, segment{isIndex: true, index: index})
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("unexpected %q", rest[0])
+		}
+	}
+	return segments, nil
+}
+
+// lookup returns value on path (e.g. "$.data.items[0].id") in value decoded
+// from JSON.
+func lookup(value interface{}, path string) (interface{}, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range segments {
+		switch {
+		case s.wildcard:
+			return nil, fmt.Errorf("wildcard can not be used for lookup")
+		case s.isIndex:
+			arr, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("can not get index %d of %s", s.index, kindOf(value))
+			}
+			if s.index < 0 || s.index >= len(arr) {
+				return nil, fmt.Errorf("index %d out of range, length is %d", s.index, len(arr))
+			}
+			value = arr[s.index]
+		default:
+			obj, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("can not get key %q of %s", s.key, kindOf(value))
+			}
+			if value, ok = obj[s.key]; !ok {
+				return nil, fmt.Errorf("key %q not found", s.key)
+			}
+		}
+	}
+	return value, nil
+}
+
+// replace replaces all values matching path segments with replacement.
+// Values that do not exist are ignored.
+func replace(value interface{}, segments []segment, replacement interface{}) {
+	if len(segments) == 0 {
+		return
+	}
+	s, last := segments[0], len(segments) == 1
+	switch t := value.(type) {
+	case map[string]interface{}:
+		if s.isIndex {
+			return
+		}
+		child, ok := t[s.key]
+		if !ok {
+			return
+		}
+		if last {
+			t[s.key] = replacement
+		} else {
+			replace(child, segments[1:], replacement)
+		}
+	case []interface{}:
+		if !s.isIndex {
+			return
+		}
+		for i := range t {
+			if !s.wildcard && i != s.index {
+				continue
+			}
+			if last {
+				t[i] = replacement
+			} else {
+				replace(t[i], segments[1:], replacement)
+			}
+		}
+	}
+}
+
+func kindOf(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", value)
+}
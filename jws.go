@@ -0,0 +1,237 @@
+package jsonresponse
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// JWSContentType is Content-Type of responses sent as compact JWS.
+const JWSContentType = "application/jose"
+
+// JWSHeader is name of header with detached JWS of response body.
+const JWSHeader = "x-jws-signature"
+
+// ErrInvalidJWS is returned when JWS can not be verified.
+var ErrInvalidJWS = errors.New("jsonresponse: invalid JWS")
+
+// JWSKey signs and verifies JWS signing input (RFC 7515).
+type JWSKey interface {
+	// Algorithm returns value of "alg" header, e.g. "HS256".
+	Algorithm() string
+	Sign(input []byte) ([]byte, error)
+	Verify(input, signature []byte) error
+}
+
+// JWSOptions configures JWS of response body, see SetJWS and Response.JWS.
+type JWSOptions struct {
+	// Key signs body, nil disables JWS.
+	Key JWSKey
+	// KeyID is included in "kid" header, if it is not empty.
+	KeyID string
+	// Detached sends body as it is, with JWS with detached payload in
+	// x-jws-signature header. Otherwise body is replaced with compact JWS
+	// and sent with application/jose Content-Type.
+	Detached bool
+}
+
+// JWS sets options of JWS of this response body, which override options set
+// via SetJWS. Body is signed after it is encoded, so JWS covers exact bytes
+// that are sent (or would be sent, for compact JWS).
+func (r Response) JWS(o JWSOptions) Response {
+	r.jws = &o
+	return r
+}
+
+// jwsBody wraps body that is signed with options of response.
+type jwsBody struct {
+	data    interface{}
+	options JWSOptions
+}
+
+type hs256Key []byte
+
+// HS256Key returns key that signs with HMAC-SHA256 using shared secret.
+func HS256Key(secret []byte) JWSKey {
+	return hs256Key(secret)
+}
+
+func (k hs256Key) Algorithm() string {
+	return "HS256"
+}
+
+func (k hs256Key) Sign(input []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, k)
+	mac.Write(input)
+	return mac.Sum(nil), nil
+}
+
+func (k hs256Key) Verify(input, signature []byte) error {
+	expected, _ := k.Sign(input)
+	if !hmac.Equal(expected, signature) {
+		return ErrInvalidJWS
+	}
+	return nil
+}
+
+type es256Key struct {
+	private *ecdsa.PrivateKey
+	public  *ecdsa.PublicKey
+}
+
+// ES256Key returns key that signs with ECDSA using P-256 private key.
+func ES256Key(private *ecdsa.PrivateKey) JWSKey {
+	return es256Key{private: private, public: &private.PublicKey}
+}
+
+// ES256PublicKey returns key that can only verify ES256 signatures.
+func ES256PublicKey(public *ecdsa.PublicKey) JWSKey {
+	return es256Key{public: public}
+}
+
+func (k es256Key) Algorithm() string {
+	return "ES256"
+}
+
+func (k es256Key) Sign(input []byte) ([]byte, error) {
+	if k.private == nil {
+		return nil, errors.New("jsonresponse: ES256 key without private key can not sign")
+	}
+	if k.private.Curve != elliptic.P256() {
+		return nil, errors.New("jsonresponse: ES256 key has to use P-256 curve")
+	}
+	digest := sha256.Sum256(input)
+	r, s, err := ecdsa.Sign(rand.Reader, k.private, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	// signature is concatenation of r and s, each padded to 32 bytes
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return signature, nil
+}
+
+func (k es256Key) Verify(input, signature []byte) error {
+	if len(signature) != 64 {
+		return ErrInvalidJWS
+	}
+	digest := sha256.Sum256(input)
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(k.public, digest[:], r, s) {
+		return ErrInvalidJWS
+	}
+	return nil
+}
+
+type eddsaKey struct {
+	private ed25519.PrivateKey
+	public  ed25519.PublicKey
+}
+
+// EdDSAKey returns key that signs with Ed25519 private key.
+func EdDSAKey(private ed25519.PrivateKey) JWSKey {
+	return eddsaKey{private: private, public: private.Public().(ed25519.PublicKey)}
+}
+
+// EdDSAPublicKey returns key that can only verify EdDSA signatures.
+func EdDSAPublicKey(public ed25519.PublicKey) JWSKey {
+	return eddsaKey{public: public}
+}
+
+func (k eddsaKey) Algorithm() string {
+	return "EdDSA"
+}
+
+func (k eddsaKey) Sign(input []byte) ([]byte, error) {
+	if k.private == nil {
+		return nil, errors.New("jsonresponse: EdDSA key without private key can not sign")
+	}
+	return ed25519.Sign(k.private, input), nil
+}
+
+func (k eddsaKey) Verify(input, signature []byte) error {
+	if !ed25519.Verify(k.public, input, signature) {
+		return ErrInvalidJWS
+	}
+	return nil
+}
+
+type jwsProtectedHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+// signJWS returns compact JWS of payload. If detached is set, payload is
+// left out of it.
+func signJWS(payload []byte, o JWSOptions, detached bool) (string, error) {
+	header, err := json.Marshal(jwsProtectedHeader{Algorithm: o.Key.Algorithm(), KeyID: o.KeyID})
+	if err != nil {
+		return "", err
+	}
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature, err := o.Key.Sign([]byte(input))
+	if err != nil {
+		return "", err
+	}
+	if detached {
+		input = input[:strings.IndexByte(input, '.')+1]
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// VerifyJWS verifies compact JWS with key and returns its payload. Algorithm
+// in header of JWS has to be algorithm of key.
+func VerifyJWS(token string, key JWSKey) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: expected 3 parts, got %d", ErrInvalidJWS, len(parts))
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJWS, err)
+	}
+	if err := verifyJWS(parts[0], parts[1], parts[2], key); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// VerifyDetachedJWS verifies JWS with detached payload (e.g. from
+// x-jws-signature header) against payload.
+func VerifyDetachedJWS(token string, payload []byte, key JWSKey) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[1] != "" {
+		return fmt.Errorf("%w: expected JWS with detached payload", ErrInvalidJWS)
+	}
+	return verifyJWS(parts[0], base64.RawURLEncoding.EncodeToString(payload), parts[2], key)
+}
+
+func verifyJWS(header, payload, signature string, key JWSKey) error {
+	b, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidJWS, err)
+	}
+	var h jwsProtectedHeader
+	if err := json.Unmarshal(b, &h); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidJWS, err)
+	}
+	if h.Algorithm != key.Algorithm() {
+		return fmt.Errorf("%w: expected algorithm %s, got %s", ErrInvalidJWS, key.Algorithm(), h.Algorithm)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidJWS, err)
+	}
+	return key.Verify([]byte(header+"."+payload), sig)
+}
@@ -0,0 +1,100 @@
+package jsonresponse
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJWSCompact(t *testing.T) {
+	SetIndent(false)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(nil)
+	for _, c := range []struct {
+		signing JWSKey
+		verify  JWSKey
+	}{
+		{HS256Key([]byte("secret")), HS256Key([]byte("secret"))},
+		{ES256Key(ecKey), ES256PublicKey(&ecKey.PublicKey)},
+		{EdDSAKey(edKey), EdDSAPublicKey(edKey.Public().(ed25519.PublicKey))},
+	} {
+		recorder := httptest.NewRecorder()
+		New(1).JWS(JWSOptions{Key: c.signing, KeyID: "k1"}).OK(recorder)
+		if recorder.Header().Get("Content-Type") != JWSContentType {
+			fmt.Printf("Expected Content-Type %s, got %s\n", JWSContentType, recorder.Header().Get("Content-Type"))
+			t.Fail()
+		}
+		payload, err := VerifyJWS(recorder.Body.String(), c.verify)
+		if err != nil || string(payload) != `{"data":1}`+"\n" {
+			fmt.Printf("Expected valid %s JWS, got payload %q (%v)\n", c.signing.Algorithm(), payload, err)
+			t.Fail()
+		}
+		header := strings.SplitN(recorder.Body.String(), ".", 2)[0]
+		if expected := `{"alg":"` + c.signing.Algorithm() + `","kid":"k1"}`; header != encodeSegment(expected) {
+			fmt.Printf("Expected header %s\n", expected)
+			t.Fail()
+		}
+
+		parts := strings.Split(recorder.Body.String(), ".")
+		tampered := parts[0] + "." + encodeSegment(`{"data":2}`) + "." + parts[2]
+		if _, err := VerifyJWS(tampered, c.verify); !errors.Is(err, ErrInvalidJWS) {
+			fmt.Printf("Expected tampered %s JWS to fail, got %v\n", c.signing.Algorithm(), err)
+			t.Fail()
+		}
+	}
+}
+
+func TestJWSDetached(t *testing.T) {
+	key := HS256Key([]byte("secret"))
+	SetJWS(JWSOptions{Key: key, Detached: true})
+	defer SetJWS(JWSOptions{})
+
+	recorder := httptest.NewRecorder()
+	NotFound(recorder, nil)
+	token := recorder.Header().Get(JWSHeader)
+	if !strings.Contains(token, "..") || recorder.Header().Get("Content-Type") == JWSContentType {
+		fmt.Printf("Expected detached JWS, got %s\n", token)
+		t.Fail()
+	}
+	if err := VerifyDetachedJWS(token, recorder.Body.Bytes(), key); err != nil {
+		fmt.Printf("Expected valid detached JWS, got %v\n", err)
+		t.Fail()
+	}
+	if err := VerifyDetachedJWS(token, []byte(`{}`), key); !errors.Is(err, ErrInvalidJWS) {
+		fmt.Printf("Expected detached JWS of other payload to fail, got %v\n", err)
+		t.Fail()
+	}
+	if err := VerifyDetachedJWS(token, recorder.Body.Bytes(), HS256Key([]byte("other"))); !errors.Is(err, ErrInvalidJWS) {
+		fmt.Printf("Expected detached JWS with other key to fail, got %v\n", err)
+		t.Fail()
+	}
+
+	// options of response override global ones
+	recorder = httptest.NewRecorder()
+	New(1).JWS(JWSOptions{}).OK(recorder)
+	if recorder.Header().Get(JWSHeader) != "" {
+		fmt.Println("Expected JWS to be disabled for response")
+		t.Fail()
+	}
+}
+
+func TestJWSAlgorithmMismatch(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(nil)
+	recorder := httptest.NewRecorder()
+	New(1).JWS(JWSOptions{Key: EdDSAKey(edKey)}).OK(recorder)
+	if _, err := VerifyJWS(recorder.Body.String(), HS256Key(edKey.Public().(ed25519.PublicKey))); !errors.Is(err, ErrInvalidJWS) {
+		fmt.Printf("Expected JWS with other algorithm to fail, got %v\n", err)
+		t.Fail()
+	}
+}
+
+func encodeSegment(s string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
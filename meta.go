@@ -0,0 +1,54 @@
+package jsonresponse
+
+import "net/http"
+
+// MetaProvider returns key and value that is added to "meta" object of every
+// response. Request is nil when response is sent without it (e.g. via OK
+// instead of OKFor). Empty key means that nothing is added.
+type MetaProvider func(r *http.Request) (string, interface{})
+
+// Meta adds key with value to "meta" object of envelope. Metadata is included
+// by default transformer and MessageCode transformers, and it overrides values
+// from meta providers (see AddMetaProvider) with same key.
+func (r Response) Meta(key string, value interface{}) Response {
+	meta := make(map[string]interface{}, len(r.Metadata)+1)
+	for k, v := range r.Metadata {
+		meta[k] = v
+	}
+	meta[key] = value
+	r.Metadata = meta
+	return r
+}
+
+// metaField returns name of field of envelope that contains metadata, from
+// configuration with which response is written.
+func (r Response) metaField() string {
+	if r.settings != nil {
+		return r.settings.metaField
+	}
+	return current().metaField
+}
+
+// metaFor returns metadata of response merged with values from meta providers
+// and with server timing metrics, if they are included in meta.
+func (r Response) metaFor(req *http.Request, timings []Timing, s *settings) map[string]interface{} {
+	providers := s.metaProviders
+	includeTimings := len(timings) > 0 && s.timingsInMeta
+	if len(providers) == 0 && !includeTimings {
+		return r.Metadata
+	}
+
+	meta := map[string]interface{}{}
+	for _, p := range providers {
+		if key, value := p(req); key != "" {
+			meta[key] = value
+		}
+	}
+	if includeTimings {
+		meta["timings"] = timingsMeta(timings)
+	}
+	for k, v := range r.Metadata {
+		meta[k] = v
+	}
+	return meta
+}
@@ -0,0 +1,80 @@
+package jsonresponse
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func responseBody(recorder *httptest.ResponseRecorder) string {
+	var body interface{}
+	json.Unmarshal(recorder.Body.Bytes(), &body)
+	b, _ := json.Marshal(body)
+	return string(b)
+}
+
+func TestMeta(t *testing.T) {
+	base := New(1).Meta("version", "v1")
+	derived := base.Meta("deprecated", true)
+
+	recorder := httptest.NewRecorder()
+	base.OK(recorder)
+	if expected := `{"data":1,"meta":{"version":"v1"}}`; responseBody(recorder) != expected {
+		fmt.Printf("Expected %s, got %s\n", expected, responseBody(recorder))
+		t.Fail()
+	}
+	recorder = httptest.NewRecorder()
+	derived.OK(recorder)
+	if expected := `{"data":1,"meta":{"deprecated":true,"version":"v1"}}`; responseBody(recorder) != expected {
+		fmt.Printf("Expected %s, got %s\n", expected, responseBody(recorder))
+		t.Fail()
+	}
+}
+
+func TestMetaProviders(t *testing.T) {
+	AddMetaProvider(func(r *http.Request) (string, interface{}) { return "version", "v1" })
+	AddMetaProvider(func(r *http.Request) (string, interface{}) {
+		if r == nil {
+			return "", nil
+		}
+		return "path", r.URL.Path
+	})
+	defer ResetMetaProviders()
+
+	recorder := httptest.NewRecorder()
+	New(1).OK(recorder)
+	if expected := `{"data":1,"meta":{"version":"v1"}}`; responseBody(recorder) != expected {
+		fmt.Printf("Expected %s, got %s\n", expected, responseBody(recorder))
+		t.Fail()
+	}
+
+	recorder = httptest.NewRecorder()
+	New(1).Meta("version", "v2").OKFor(recorder, httptest.NewRequest("GET", "/items", nil))
+	if expected := `{"data":1,"meta":{"path":"/items","version":"v2"}}`; responseBody(recorder) != expected {
+		fmt.Printf("Expected %s, got %s\n", expected, responseBody(recorder))
+		t.Fail()
+	}
+}
+
+func TestMetaField(t *testing.T) {
+	SetMetaField("_meta")
+	defer SetMetaField("meta")
+	SetEnvelope(MessageCodeTransformer("payload", "code"))
+	defer ResetTransformer()
+
+	recorder := httptest.NewRecorder()
+	New(1).Meta("version", "v1").OK(recorder)
+	if expected := `{"_meta":{"version":"v1"},"code":200,"payload":1}`; responseBody(recorder) != expected {
+		fmt.Printf("Expected %s, got %s\n", expected, responseBody(recorder))
+		t.Fail()
+	}
+
+	recorder = httptest.NewRecorder()
+	New(1).OK(recorder)
+	if expected := `{"code":200,"payload":1}`; responseBody(recorder) != expected {
+		fmt.Printf("Expected %s, got %s\n", expected, responseBody(recorder))
+		t.Fail()
+	}
+}
@@ -0,0 +1,145 @@
+package jsonresponse
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// KeyCasing defines how names of struct fields without name in json tag are
+// written as keys of JSON objects (see SetKeyCasing).
+type KeyCasing int
+
+const (
+	// KeepCase writes names of fields as they are, same as encoding/json.
+	KeepCase KeyCasing = iota
+	// SnakeCase writes names like "user_id".
+	SnakeCase
+	// CamelCase writes names like "userId".
+	CamelCase
+	// KebabCase writes names like "user-id".
+	KebabCase
+)
+
+// keyNaming is casing of keys with list of acronyms.
+type keyNaming struct {
+	casing   KeyCasing
+	acronyms []string
+	// id identifies naming in caches of fields, see cachedFields
+	id string
+}
+
+func newKeyNaming(casing KeyCasing, acronyms []string) keyNaming {
+	n := keyNaming{casing: casing, acronyms: append([]string(nil), acronyms...)}
+	if casing != KeepCase {
+		n.id = strconv.Itoa(int(casing)) + ":" + strings.Join(acronyms, ",")
+	}
+	return n
+}
+
+// rename returns name of field written in casing.
+func (n keyNaming) rename(name string) string {
+	if n.casing == KeepCase {
+		return name
+	}
+	words := n.split(name)
+	switch n.casing {
+	case SnakeCase, KebabCase:
+		for i, w := range words {
+			words[i] = strings.ToLower(w)
+		}
+		separator := "_"
+		if n.casing == KebabCase {
+			separator = "-"
+		}
+		return strings.Join(words, separator)
+	case CamelCase:
+		for i, w := range words {
+			switch {
+			case i == 0:
+				words[i] = strings.ToLower(w)
+			case n.isAcronym(w):
+				// acronyms keep their case, e.g. "userID"
+			default:
+				words[i] = strings.ToUpper(w[:1]) + strings.ToLower(w[1:])
+			}
+		}
+		return strings.Join(words, "")
+	}
+	return name
+}
+
+// split splits name of field to words on changes of case and underscores.
+// Sequence of upper case letters is single word (e.g. "HTTPServer" is split
+// to "HTTP" and "Server"), and acronyms are always separate words (e.g.
+// "IDs" is single word if "ID" is acronym).
+func (n keyNaming) split(name string) []string {
+	var words []string
+	runes := []rune(name)
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '_' {
+			if i > start {
+				words = append(words, string(runes[start:i]))
+			}
+			start = i + 1
+			continue
+		}
+		if i == start {
+			if a := n.acronymAt(runes[i:]); a > 0 {
+				words = append(words, string(runes[i:i+a]))
+				start = i + a
+				i += a - 1
+			}
+			continue
+		}
+		prev, cur := runes[i-1], runes[i]
+		boundary := unicode.IsLower(prev) && unicode.IsUpper(cur) ||
+			unicode.IsDigit(prev) && unicode.IsUpper(cur) ||
+			unicode.IsUpper(prev) && unicode.IsUpper(cur) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+		if boundary {
+			words = append(words, string(runes[start:i]))
+			start = i
+			if a := n.acronymAt(runes[i:]); a > 0 {
+				words = append(words, string(runes[i:i+a]))
+				start = i + a
+				i += a - 1
+			}
+		}
+	}
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
+
+// acronymAt returns length of acronym at start of runes, including "s" if
+// it is in plural (e.g. "IDs"), or 0 if there is no acronym. Acronym has to
+// be followed by end of name or by letter that is not lower case.
+func (n keyNaming) acronymAt(runes []rune) int {
+	endsWord := func(i int) bool {
+		return i == len(runes) || !unicode.IsLower(runes[i])
+	}
+	for _, a := range n.acronyms {
+		length := len([]rune(a))
+		if length > len(runes) || string(runes[:length]) != a {
+			continue
+		}
+		if endsWord(length) {
+			return length
+		}
+		if runes[length] == 's' && endsWord(length+1) {
+			return length + 1
+		}
+	}
+	return 0
+}
+
+func (n keyNaming) isAcronym(word string) bool {
+	for _, a := range n.acronyms {
+		if word == a || word == a+"s" {
+			return true
+		}
+	}
+	return false
+}
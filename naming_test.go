@@ -0,0 +1,99 @@
+package jsonresponse
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+)
+
+type legacyAddress struct {
+	StreetName string
+	ZIPCode    string
+}
+
+type legacyUser struct {
+	UserID      int
+	ImageURLs   []string
+	HTTPServer  string
+	Address2    legacyAddress
+	DisplayName string
+	Tagged      string `json:"display_name"`
+	Password    string `jsonresponse:"redact"`
+}
+
+func TestKeyNamingRename(t *testing.T) {
+	for _, c := range []struct {
+		naming   keyNaming
+		name     string
+		expected string
+	}{
+		{keyNaming{casing: SnakeCase}, "UserID", "user_id"},
+		{keyNaming{casing: SnakeCase}, "HTTPServer", "http_server"},
+		{keyNaming{casing: SnakeCase}, "Address2Line", "address2_line"},
+		{keyNaming{casing: SnakeCase}, "Legacy_Name", "legacy_name"},
+		{keyNaming{casing: SnakeCase}, "IDs", "i_ds"},
+		{keyNaming{casing: SnakeCase, acronyms: []string{"ID"}}, "IDs", "ids"},
+		{keyNaming{casing: SnakeCase, acronyms: []string{"ID"}}, "Identity", "identity"},
+		{keyNaming{casing: KebabCase}, "ImageURLs", "image-ur-ls"},
+		{keyNaming{casing: KebabCase, acronyms: []string{"URL"}}, "ImageURLs", "image-urls"},
+		{keyNaming{casing: CamelCase}, "UserID", "userId"},
+		{keyNaming{casing: CamelCase, acronyms: []string{"ID", "URL"}}, "UserID", "userID"},
+		{keyNaming{casing: CamelCase, acronyms: []string{"ID", "URL"}}, "ImageURLs", "imageURLs"},
+		{keyNaming{casing: CamelCase, acronyms: []string{"ID", "URL"}}, "URLPath", "urlPath"},
+		{keyNaming{casing: KeepCase}, "UserID", "UserID"},
+	} {
+		if got := c.naming.rename(c.name); got != c.expected {
+			fmt.Printf("Rename of %s with %+v: expected %s, got %s\n", c.name, c.naming, c.expected, got)
+			t.Fail()
+		}
+	}
+}
+
+func TestKeyCasing(t *testing.T) {
+	SetIndent(false)
+	user := legacyUser{UserID: 1, ImageURLs: []string{"a"}, HTTPServer: "s", Address2: legacyAddress{"Main", "123"},
+		DisplayName: "camel", Tagged: "snake", Password: "secret"}
+	for _, c := range []struct {
+		casing   KeyCasing
+		expected string
+	}{
+		{SnakeCase, `{"data":{"user_id":1,"image_urls":["a"],"http_server":"s","address2":{"street_name":"Main","zip_code":"123"},` +
+			`"display_name":"snake","password":"[REDACTED]"}}` + "\n"},
+		{CamelCase, `{"data":{"userID":1,"imageURLs":["a"],"httpServer":"s","address2":{"streetName":"Main","zipCode":"123"},` +
+			`"displayName":"camel","display_name":"snake","password":"[REDACTED]"}}` + "\n"},
+	} {
+		SetKeyCasing(c.casing, "ID", "URL")
+		for _, fast := range []bool{false, true} {
+			SetFastEncoder(fast)
+			recorder := httptest.NewRecorder()
+			New(user).OK(recorder)
+			if recorder.Body.String() != c.expected {
+				fmt.Printf("Expected %s\nbut got  %s\n", c.expected, recorder.Body.String())
+				t.Fail()
+			}
+		}
+	}
+	SetFastEncoder(false)
+	SetKeyCasing(KeepCase)
+
+	recorder := httptest.NewRecorder()
+	New(legacyAddress{"Main", "123"}).OK(recorder)
+	if expected := `{"data":{"StreetName":"Main","ZIPCode":"123"}}` + "\n"; recorder.Body.String() != expected {
+		fmt.Printf("Expected %s\nbut got  %s\n", expected, recorder.Body.String())
+		t.Fail()
+	}
+}
+
+func TestKeyCasingFields(t *testing.T) {
+	SetIndent(false)
+	SetKeyCasing(SnakeCase)
+	defer SetKeyCasing(KeepCase)
+
+	recorder := httptest.NewRecorder()
+	New(legacyUser{UserID: 1, Address2: legacyAddress{StreetName: "Main"}}).
+		OKFor(recorder, httptest.NewRequest("GET", "/?fields=user_id,address2.street_name", nil))
+	if expected := `{"data":{"user_id":1,"address2":{"street_name":"Main"}}}` + "\n"; recorder.Body.String() != expected {
+		fmt.Printf("Expected %s\nbut got  %s\n", expected, recorder.Body.String())
+		t.Fail()
+	}
+}
@@ -0,0 +1,137 @@
+package jsonresponse
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// maxSafeInteger is largest integer that JavaScript numbers represent exactly.
+const maxSafeInteger = 1<<53 - 1
+
+// NonFiniteMode defines how NaN and infinite floats are encoded.
+type NonFiniteMode int
+
+const (
+	// NonFiniteError fails encoding, same as encoding/json does.
+	NonFiniteError NonFiniteMode = iota
+	// NonFiniteNull encodes NaN and infinities as null.
+	NonFiniteNull
+	// NonFiniteString encodes NaN and infinities as strings "NaN", "Infinity"
+	// and "-Infinity", which JavaScript Number function understands.
+	NonFiniteString
+)
+
+// NumberPolicy defines how numbers in response data are encoded, so they
+// are safe for clients that can not represent all of them (like JavaScript).
+// Zero value encodes numbers same as encoding/json.
+type NumberPolicy struct {
+	// BigIntsAsStrings encodes integers that can not be represented exactly
+	// by JavaScript numbers (outside of ±(2^53-1)) as strings.
+	BigIntsAsStrings bool
+	// NonFinite defines how NaN and infinite floats are encoded.
+	NonFinite NonFiniteMode
+	// FloatDigits is number of digits after decimal point of floats. If it
+	// is not positive, shortest representation is used.
+	FloatDigits int
+}
+
+// Numbers sets policy of encoding numbers in data of this response, which
+// overrides policy set via SetNumberPolicy.
+func (r Response) Numbers(p NumberPolicy) Response {
+	r.numbers = &p
+	return r
+}
+
+// numberPolicyFor returns policy of response, or global one if it has none.
+func (r Response) numberPolicyFor(s *settings) NumberPolicy {
+	if r.numbers != nil {
+		return *r.numbers
+	}
+	return s.numberPolicy
+}
+
+// applyNumberPolicy returns body converted to tree in which numbers are
+// replaced according to policy. Body is returned unchanged for zero policy.
+// Body is converted with provided snapshot of configuration.
+func applyNumberPolicy(body interface{}, p NumberPolicy, s *settings) interface{} {
+	if p == (NumberPolicy{}) || body == nil {
+		return body
+	}
+	return p.apply(toTree(body, s))
+}
+
+func (p NumberPolicy) apply(v interface{}) interface{} {
+	switch t := v.(type) {
+	case *object:
+		for _, k := range t.keys {
+			t.values[k] = p.apply(t.values[k])
+		}
+		return t
+	case []interface{}:
+		for i, item := range t {
+			t[i] = p.apply(item)
+		}
+		return t
+	case *filtered:
+		return p.apply(t.tree())
+	case json.Number:
+		if p.BigIntsAsStrings && isUnsafeInteger(string(t)) {
+			return t.String()
+		}
+		return t
+	case nil:
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i := rv.Int(); p.BigIntsAsStrings && (i > maxSafeInteger || i < -maxSafeInteger) {
+			return strconv.FormatInt(i, 10)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if p.BigIntsAsStrings && rv.Uint() > maxSafeInteger {
+			return strconv.FormatUint(rv.Uint(), 10)
+		}
+	case reflect.Float32, reflect.Float64:
+		return p.float(rv.Float(), rv.Type().Bits(), v)
+	}
+	return v
+}
+
+// isUnsafeInteger returns true if number is integer that can not be
+// represented exactly by JavaScript numbers.
+func isUnsafeInteger(number string) bool {
+	i, err := strconv.ParseInt(number, 10, 64)
+	if err != nil {
+		numErr, ok := err.(*strconv.NumError)
+		return ok && numErr.Err == strconv.ErrRange
+	}
+	return i > maxSafeInteger || i < -maxSafeInteger
+}
+
+// float returns value that is encoded for float f, or original value if
+// policy does not change it.
+func (p NumberPolicy) float(f float64, bits int, original interface{}) interface{} {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		switch p.NonFinite {
+		case NonFiniteNull:
+			return nil
+		case NonFiniteString:
+			switch {
+			case math.IsNaN(f):
+				return "NaN"
+			case f > 0:
+				return "Infinity"
+			}
+			return "-Infinity"
+		}
+		return original
+	}
+	if p.FloatDigits > 0 {
+		return json.Number(strconv.FormatFloat(f, 'f', p.FloatDigits, bits))
+	}
+	return original
+}
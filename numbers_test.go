@@ -0,0 +1,88 @@
+package jsonresponse
+
+import (
+	"fmt"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type measurement struct {
+	ID    int64   `json:"id"`
+	Count uint64  `json:"count"`
+	Value float64 `json:"value"`
+	Ratio float32 `json:"ratio"`
+	Raw   float64 `json:"raw,string"`
+}
+
+func TestNumberPolicy(t *testing.T) {
+	SetIndent(false)
+	data := []interface{}{
+		measurement{ID: 1 << 60, Count: 1 << 63, Value: math.NaN(), Ratio: 0.5, Raw: 1.25},
+		measurement{ID: -(1 << 53), Count: 1 << 53, Value: math.Inf(-1), Ratio: 1.0 / 3, Raw: 2},
+		map[string]interface{}{"small": 42, "inf": math.Inf(1)},
+	}
+	for _, c := range []struct {
+		policy   NumberPolicy
+		expected string
+	}{
+		{NumberPolicy{BigIntsAsStrings: true, NonFinite: NonFiniteNull},
+			`[{"id":"1152921504606846976","count":"9223372036854775808","value":null,"ratio":0.5,"raw":"1.25"},` +
+				`{"id":"-9007199254740992","count":"9007199254740992","value":null,"ratio":0.33333334,"raw":"2"},` +
+				`{"inf":null,"small":42}]`},
+		{NumberPolicy{NonFinite: NonFiniteString, FloatDigits: 2},
+			`[{"id":1152921504606846976,"count":9223372036854775808,"value":"NaN","ratio":0.50,"raw":"1.25"},` +
+				`{"id":-9007199254740992,"count":9007199254740992,"value":"-Infinity","ratio":0.33,"raw":"2"},` +
+				`{"inf":"Infinity","small":42}]`},
+	} {
+		for _, fast := range []bool{false, true} {
+			SetFastEncoder(fast)
+			recorder := httptest.NewRecorder()
+			New(data).Numbers(c.policy).OK(recorder)
+			expected := `{"data":` + c.expected + "}\n"
+			if recorder.Body.String() != expected {
+				fmt.Printf("Expected %s\nbut got  %s\n", expected, recorder.Body.String())
+				t.Fail()
+			}
+		}
+	}
+	SetFastEncoder(false)
+}
+
+func TestGlobalNumberPolicy(t *testing.T) {
+	SetIndent(false)
+	SetNumberPolicy(NumberPolicy{BigIntsAsStrings: true})
+	defer SetNumberPolicy(NumberPolicy{})
+
+	recorder := httptest.NewRecorder()
+	New(map[string]int64{"id": 1 << 60}).OK(recorder)
+	if !strings.Contains(recorder.Body.String(), `"id":"1152921504606846976"`) {
+		fmt.Printf("Expected ID as string, got %s\n", recorder.Body.String())
+		t.Fail()
+	}
+
+	recorder = httptest.NewRecorder()
+	New(map[string]int64{"id": 1 << 60}).Numbers(NumberPolicy{}).OK(recorder)
+	if !strings.Contains(recorder.Body.String(), `"id":1152921504606846976`) {
+		fmt.Printf("Expected policy of response to override global one, got %s\n", recorder.Body.String())
+		t.Fail()
+	}
+
+	recorder = httptest.NewRecorder()
+	OK(recorder, map[string]interface{}{"id": uint64(1 << 60)})
+	if recorder.Body.String() != `{"id":"1152921504606846976"}`+"\n" {
+		fmt.Printf("Expected ID as string in Respond, got %s\n", recorder.Body.String())
+		t.Fail()
+	}
+}
+
+func TestNumberPolicyNonFiniteError(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			fmt.Println("Expected encoding of NaN to fail")
+			t.Fail()
+		}
+	}()
+	New(math.NaN()).Numbers(NumberPolicy{BigIntsAsStrings: true}).OK(httptest.NewRecorder())
+}
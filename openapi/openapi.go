@@ -0,0 +1,274 @@
+// Package openapi builds OpenAPI 3.1 document of API that sends responses
+// using jsonresponse. Handlers are registered with description of operation
+// they implement, and schemas of responses include envelope of transformers
+// that are set in jsonresponse.
+//
+// Example:
+//
+//	mux.Handle("/users/{id}", openapi.Register(openapi.Operation{
+//		Method:    "GET",
+//		Path:      "/users/{id}",
+//		Summary:   "Get user",
+//		Responses: map[int]interface{}{http.StatusOK: User{}},
+//		Errors:    map[int]interface{}{http.StatusNotFound: nil},
+//	}, getUserHandler))
+//
+//	mux.HandleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+//		jsonresponse.New(openapi.Build()).OK(w)
+//	})
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/delicb/jsonresponse"
+)
+
+// Version is version of OpenAPI specification of built documents.
+const Version = "3.1.0"
+
+// Info contains metadata about API.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Operation describes single operation of API.
+type Operation struct {
+	// Method is HTTP method, e.g. "GET".
+	Method string
+	// Path of operation, parameters are in braces, e.g. "/users/{id}".
+	Path        string
+	OperationID string
+	Summary     string
+	Description string
+	Tags        []string
+	// Request is value of type that is expected in request body, or nil
+	// if operation does not expect body.
+	Request interface{}
+	// Responses contains values of types of data of responses for each status
+	// code. Data is wrapped in envelope of transformer used for status code.
+	Responses map[int]interface{}
+	// Errors contains values of types of error responses sent without envelope
+	// (e.g. via NotFound function) for each status code. Nil value means
+	// that jsonresponse.MessageResponse is sent.
+	Errors map[int]interface{}
+}
+
+var (
+	registryLock = &sync.Mutex{}
+
+	info       = Info{Title: "API", Version: "1.0.0"}
+	operations []Operation
+)
+
+// SetInfo sets metadata about API included in document.
+func SetInfo(i Info) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	info = i
+}
+
+// Register adds operation to document and returns provided handler, so it
+// can be used when handler is added to router.
+func Register(op Operation, handler http.Handler) http.Handler {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	operations = append(operations, op)
+	return handler
+}
+
+// Reset removes all registered operations.
+func Reset() {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	operations = nil
+}
+
+// Document is OpenAPI document. It is encoded to JSON as it is, regardless
+// of transformer set in jsonresponse, so it can be sent via jsonresponse.New(doc).OK(w).
+type Document struct {
+	OpenAPI    string                                       `json:"openapi"`
+	Info       Info                                         `json:"info"`
+	Paths      map[string]map[string]map[string]interface{} `json:"paths"`
+	Components map[string]interface{}                       `json:"components,omitempty"`
+}
+
+// Transformer returns transformer that sends document without envelope.
+func (d *Document) Transformer() jsonresponse.RequestTransformer {
+	return jsonresponse.AdaptTransformer(jsonresponse.PassthroughTransformer)
+}
+
+// JSON returns document encoded to JSON.
+func (d *Document) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// YAML returns document encoded to YAML.
+func (d *Document) YAML() ([]byte, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	decoder := json.NewDecoder(strings.NewReader(string(b)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return encodeYAML(value), nil
+}
+
+// Build builds document from all registered operations.
+func Build() *Document {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	b := &builder{schemas: jsonresponse.NewSchemaGenerator()}
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]map[string]map[string]interface{}{},
+	}
+	for _, op := range operations {
+		if doc.Paths[op.Path] == nil {
+			doc.Paths[op.Path] = map[string]map[string]interface{}{}
+		}
+		doc.Paths[op.Path][strings.ToLower(op.Method)] = b.operation(op)
+	}
+	if defs := b.schemas.Definitions(); len(defs) > 0 {
+		doc.Components = map[string]interface{}{"schemas": rewriteRefs(defs)}
+	}
+	return doc
+}
+
+type builder struct {
+	// schemas of named types, shared by all operations
+	schemas *jsonresponse.SchemaGenerator
+}
+
+func (b *builder) operation(op Operation) map[string]interface{} {
+	operation := map[string]interface{}{}
+	if op.OperationID != "" {
+		operation["operationId"] = op.OperationID
+	}
+	if op.Summary != "" {
+		operation["summary"] = op.Summary
+	}
+	if op.Description != "" {
+		operation["description"] = op.Description
+	}
+	if len(op.Tags) > 0 {
+		operation["tags"] = op.Tags
+	}
+	if parameters := pathParameters(op.Path); len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+	if op.Request != nil {
+		operation["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  b.content(op.Request, jsonresponse.Envelope{}),
+		}
+	}
+
+	responses := map[string]interface{}{}
+	// codes are sorted, so types get same names in every build
+	for _, code := range sortedCodes(op.Responses) {
+		data := op.Responses[code]
+		response := map[string]interface{}{"description": http.StatusText(code)}
+		if data != nil {
+			response["content"] = b.content(data, transformerFor(code))
+		}
+		responses[strconv.Itoa(code)] = response
+	}
+	for _, code := range sortedCodes(op.Errors) {
+		data := op.Errors[code]
+		if data == nil {
+			data = jsonresponse.MessageResponse{}
+		}
+		responses[strconv.Itoa(code)] = map[string]interface{}{
+			"description": http.StatusText(code),
+			"content":     b.content(data, jsonresponse.Envelope{}),
+		}
+	}
+	operation["responses"] = responses
+	return operation
+}
+
+func sortedCodes(m map[int]interface{}) []int {
+	codes := make([]int, 0, len(m))
+	for code := range m {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	return codes
+}
+
+// transformerFor returns envelope with path of data of transformer used for
+// status code, or transformer itself if it does not declare path, in which
+// case its envelope is not described.
+func transformerFor(code int) interface{} {
+	if path := jsonresponse.DataPath(code); path != nil {
+		return jsonresponse.Envelope{Path: path}
+	}
+	return jsonresponse.TransformerFor(code)
+}
+
+// content returns JSON content with schema of data in envelope of transformer.
+// Definitions of named types are shared by components of document.
+func (b *builder) content(data interface{}, transformer interface{}) map[string]interface{} {
+	schema := b.schemas.Schema(data, transformer)
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": rewriteRefs(schema)},
+	}
+}
+
+// rewriteRefs changes references to definitions of schema to references to
+// components of document.
+func rewriteRefs(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, value := range t {
+			if ref, ok := value.(string); ok && k == "$ref" {
+				t[k] = strings.Replace(ref, "#/$defs/", "#/components/schemas/", 1)
+				continue
+			}
+			t[k] = rewriteRefs(value)
+		}
+	case []interface{}:
+		for i, value := range t {
+			t[i] = rewriteRefs(value)
+		}
+	}
+	return v
+}
+
+// pathParameters returns parameters for names in braces in path.
+func pathParameters(path string) []interface{} {
+	var names []string
+	for {
+		start := strings.IndexByte(path, '{')
+		end := strings.IndexByte(path, '}')
+		if start < 0 || end < start {
+			break
+		}
+		names = append(names, path[start+1:end])
+		path = path[end+1:]
+	}
+	parameters := make([]interface{}, len(names))
+	for i, name := range names {
+		parameters[i] = map[string]interface{}{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		}
+	}
+	return parameters
+}
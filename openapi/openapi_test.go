@@ -0,0 +1,201 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/delicb/jsonresponse"
+)
+
+type user struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type createUser struct {
+	Name string `json:"name"`
+}
+
+func registerUsers() {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	Register(Operation{
+		Method:      "GET",
+		Path:        "/users/{id}",
+		OperationID: "getUser",
+		Summary:     "Get user",
+		Responses:   map[int]interface{}{http.StatusOK: user{}},
+		Errors:      map[int]interface{}{http.StatusNotFound: nil},
+	}, handler)
+	Register(Operation{
+		Method:    "POST",
+		Path:      "/users",
+		Tags:      []string{"users"},
+		Request:   createUser{},
+		Responses: map[int]interface{}{http.StatusCreated: user{}, http.StatusNoContent: nil},
+		Errors:    map[int]interface{}{http.StatusUnprocessableEntity: jsonresponse.Problem{}},
+	}, handler)
+}
+
+func decode(t *testing.T, doc *Document) map[string]interface{} {
+	b, err := doc.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var value map[string]interface{}
+	json.Unmarshal(b, &value)
+	return value
+}
+
+func lookup(value interface{}, path ...string) interface{} {
+	for _, key := range path {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+	return value
+}
+
+func TestBuild(t *testing.T) {
+	SetInfo(Info{Title: "Users", Version: "2.0.0"})
+	registerUsers()
+	defer Reset()
+
+	doc := decode(t, Build())
+	for _, c := range []struct {
+		path     []string
+		expected string
+	}{
+		{[]string{"openapi"}, `"3.1.0"`},
+		{[]string{"info"}, `{"title":"Users","version":"2.0.0"}`},
+		{[]string{"paths", "/users/{id}", "get", "parameters"}, `[{"in":"path","name":"id","required":true,"schema":{"type":"string"}}]`},
+		{[]string{"paths", "/users/{id}", "get", "responses", "200", "content", "application/json", "schema"},
+			`{"properties":{"data":{"$ref":"#/components/schemas/user"}},"required":["data"],"type":"object"}`},
+		{[]string{"paths", "/users/{id}", "get", "responses", "404", "content", "application/json", "schema"}, `{"$ref":"#/components/schemas/MessageResponse"}`},
+		{[]string{"paths", "/users", "post", "requestBody", "content", "application/json", "schema"}, `{"$ref":"#/components/schemas/createUser"}`},
+		{[]string{"paths", "/users", "post", "responses", "204"}, `{"description":"No Content"}`},
+		{[]string{"components", "schemas", "user"}, `{"additionalProperties":false,"properties":{"id":{"type":"integer"},"name":{"type":"string"}},"required":["id","name"],"type":"object"}`},
+		{[]string{"components", "schemas", "Problem", "type"}, `"object"`},
+	} {
+		b, _ := json.Marshal(lookup(doc, c.path...))
+		if string(b) != c.expected {
+			fmt.Printf("%s: expected %s\nbut got %s\n", strings.Join(c.path, "."), c.expected, b)
+			t.Fail()
+		}
+	}
+}
+
+func TestBuildWithCustomTransformer(t *testing.T) {
+	jsonresponse.SetEnvelope(jsonresponse.MessageCodeTransformer("payload", "code"))
+	defer jsonresponse.ResetTransformer()
+	registerUsers()
+	defer Reset()
+
+	doc := decode(t, Build())
+	expected := `{"properties":{"payload":{"$ref":"#/components/schemas/user"}},"required":["payload"],"type":"object"}`
+	b, _ := json.Marshal(lookup(doc, "paths", "/users", "post", "responses", "201", "content", "application/json", "schema"))
+	if string(b) != expected {
+		fmt.Printf("Expected %s\nbut got %s\n", expected, b)
+		t.Fail()
+	}
+}
+
+// MessageResponse has same name as type of error responses of jsonresponse.
+type MessageResponse struct {
+	Text string `json:"text"`
+}
+
+func TestBuildQualifiesSameNamedTypes(t *testing.T) {
+	Register(Operation{
+		Method:    "GET",
+		Path:      "/messages",
+		Responses: map[int]interface{}{http.StatusOK: MessageResponse{}},
+		Errors:    map[int]interface{}{http.StatusNotFound: nil, http.StatusBadRequest: nil},
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer Reset()
+
+	doc := decode(t, Build())
+	for _, c := range []struct {
+		path     []string
+		expected string
+	}{
+		{[]string{"paths", "/messages", "get", "responses", "200", "content", "application/json", "schema", "properties", "data"}, `{"$ref":"#/components/schemas/MessageResponse"}`},
+		{[]string{"paths", "/messages", "get", "responses", "400", "content", "application/json", "schema"}, `{"$ref":"#/components/schemas/jsonresponse.MessageResponse"}`},
+		{[]string{"paths", "/messages", "get", "responses", "404", "content", "application/json", "schema"}, `{"$ref":"#/components/schemas/jsonresponse.MessageResponse"}`},
+		{[]string{"components", "schemas", "MessageResponse", "required"}, `["text"]`},
+		{[]string{"components", "schemas", "jsonresponse.MessageResponse", "type"}, `"object"`},
+	} {
+		b, _ := json.Marshal(lookup(doc, c.path...))
+		if string(b) != c.expected {
+			fmt.Printf("%s: expected %s\nbut got %s\n", strings.Join(c.path, "."), c.expected, b)
+			t.Fail()
+		}
+	}
+}
+
+func TestServeDocument(t *testing.T) {
+	registerUsers()
+	defer Reset()
+
+	recorder := httptest.NewRecorder()
+	jsonresponse.New(Build()).OK(recorder)
+	doc := map[string]interface{}{}
+	json.Unmarshal(recorder.Body.Bytes(), &doc)
+	if doc["openapi"] != Version || doc["data"] != nil {
+		fmt.Printf("Expected document without envelope, got %s\n", recorder.Body.String())
+		t.Fail()
+	}
+}
+
+func TestYAML(t *testing.T) {
+	SetInfo(Info{Title: "Users: API", Version: "2.0.0"})
+	registerUsers()
+	defer Reset()
+
+	b, err := Build().YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	yaml := string(b)
+	for _, expected := range []string{
+		"openapi: \"3.1.0\"\n",
+		"info:\n  title: \"Users: API\"\n  version: \"2.0.0\"\n",
+		"  \"/users/{id}\":\n    get:\n      operationId: getUser\n      parameters:\n      - in: path\n        name: id\n        required: true\n",
+		"      tags:\n      - users\n",
+		"                \"$ref\": \"#/components/schemas/user\"\n",
+	} {
+		if !strings.Contains(yaml, expected) {
+			fmt.Printf("Expected YAML to contain\n%s\nbut got\n%s\n", expected, yaml)
+			t.Fail()
+		}
+	}
+}
+
+func TestScalar(t *testing.T) {
+	for value, expected := range map[interface{}]string{
+		nil:                "null",
+		true:               "true",
+		json.Number("1.5"): "1.5",
+		"plain text":       "plain text",
+		"true":             `"true"`,
+		"":                 `""`,
+		"a: b":             `"a: b"`,
+		"#comment":         `"#comment"`,
+		"line\nbreak":      `"line\nbreak"`,
+		"/users/me":        "/users/me",
+		"application/json": "application/json",
+		"1.0":              `"1.0"`,
+		"trailing space ":  `"trailing space "`,
+		"<b>":              `"<b>"`,
+	} {
+		if got := scalar(value); got != expected {
+			fmt.Printf("Scalar of %#v: expected %s, got %s\n", value, expected, got)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// encodeYAML encodes value decoded from JSON (with numbers as json.Number)
+// to YAML in block style.
+func encodeYAML(v interface{}) []byte {
+	var buf bytes.Buffer
+	if isBlock(v) {
+		writeBlock(&buf, v, 0, false)
+	} else {
+		buf.WriteString(scalar(v))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// isBlock returns true for values written in block style, which are objects
+// and arrays that are not empty.
+func isBlock(v interface{}) bool {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		return len(t) > 0
+	case []interface{}:
+		return len(t) > 0
+	}
+	return false
+}
+
+// writeBlock writes object or array with lines indented by provided number
+// of spaces. If inline is set, first line is not indented, since it follows
+// "- " of array item.
+func writeBlock(buf *bytes.Buffer, v interface{}, indent int, inline bool) {
+	pad := strings.Repeat(" ", indent)
+	first := true
+	startLine := func() {
+		if !first || !inline {
+			buf.WriteString(pad)
+		}
+		first = false
+	}
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			startLine()
+			buf.WriteString(quote(k))
+			buf.WriteByte(':')
+			child := t[k]
+			if !isBlock(child) {
+				buf.WriteByte(' ')
+				buf.WriteString(scalar(child))
+				buf.WriteByte('\n')
+				continue
+			}
+			buf.WriteByte('\n')
+			if _, ok := child.([]interface{}); ok {
+				// items of array can be on same indentation as key
+				writeBlock(buf, child, indent, false)
+			} else {
+				writeBlock(buf, child, indent+2, false)
+			}
+		}
+	case []interface{}:
+		for _, item := range t {
+			startLine()
+			buf.WriteString("- ")
+			if isBlock(item) {
+				writeBlock(buf, item, indent+2, true)
+				continue
+			}
+			buf.WriteString(scalar(item))
+			buf.WriteByte('\n')
+		}
+	}
+}
+
+// scalar returns YAML representation of value that is not written in block style.
+func scalar(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		if t {
+			return "true"
+		}
+		return "false"
+	case json.Number:
+		return t.String()
+	case string:
+		return quote(t)
+	case map[string]interface{}:
+		return "{}"
+	case []interface{}:
+		return "[]"
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// quote returns string as plain YAML scalar if that is safe, otherwise as
+// double quoted scalar (JSON strings are valid YAML).
+func quote(s string) string {
+	if isPlain(s) {
+		return s
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// isPlain returns true if string can be written without quotes and is not
+// read as value of other type (e.g. "true" or "null").
+func isPlain(s string) bool {
+	if s == "" || s[len(s)-1] == ' ' {
+		return false
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "null", "yes", "no", "on", "off", "y", "n", "~":
+		return false
+	}
+	for i, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_', c == '/':
+		case i > 0 && (c >= '0' && c <= '9' || c == '.' || c == '-' || c == ' '):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,191 @@
+package jsonresponse
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Names of query parameters used in links to other pages.
+const (
+	offsetParam = "offset"
+	limitParam  = "limit"
+	cursorParam = "cursor"
+)
+
+// ErrInvalidCursor is returned when cursor can not be decoded or when
+// signature of signed cursor does not match.
+var ErrInvalidCursor = errors.New("jsonresponse: invalid cursor")
+
+// PageInfo describes page of collection that is sent to client.
+// Offset pagination is used by default. If any of cursor fields is set,
+// cursor pagination is used instead and offset is ignored.
+type PageInfo struct {
+	// Offset of first item in page (for offset pagination).
+	Offset int
+	// Limit is maximum number of items in page.
+	Limit int
+	// Total number of items in collection. For cursor pagination it can be
+	// left as zero if it is not known.
+	Total int
+
+	// Cursor of current page (for cursor pagination).
+	Cursor string
+	// NextCursor is cursor of next page, empty if this is last page.
+	NextCursor string
+	// PrevCursor is cursor of previous page, empty if this is first page.
+	PrevCursor string
+}
+
+// Page creates response with items of collection page. Paging information
+// is included in "meta" object of envelope (as total, offset, limit,
+// next_cursor and prev_cursor fields). When response is sent for request
+// (e.g. via OKFor), Link header (RFC 8288) with first, prev, next and last
+// URLs is added, built from URL of request.
+func Page(items interface{}, info PageInfo) Response {
+	r := New(items)
+	r.Metadata = info.meta()
+	r.page = &info
+	return r
+}
+
+func (p PageInfo) isCursor() bool {
+	return p.Cursor != "" || p.NextCursor != "" || p.PrevCursor != ""
+}
+
+// meta returns paging fields that are included in envelope.
+func (p PageInfo) meta() map[string]interface{} {
+	meta := map[string]interface{}{}
+	if p.Limit > 0 {
+		meta["limit"] = p.Limit
+	}
+	if !p.isCursor() {
+		meta["offset"] = p.Offset
+		meta["total"] = p.Total
+		return meta
+	}
+	if p.Total > 0 {
+		meta["total"] = p.Total
+	}
+	if p.NextCursor != "" {
+		meta["next_cursor"] = p.NextCursor
+	}
+	if p.PrevCursor != "" {
+		meta["prev_cursor"] = p.PrevCursor
+	}
+	return meta
+}
+
+// link returns value of Link header for page, with links built from
+// provided URL of request.
+func (p PageInfo) link(u *url.URL) string {
+	var links []string
+	add := func(rel string, params map[string]string) {
+		target := *u
+		query := target.Query()
+		for k, v := range params {
+			if v == "" {
+				query.Del(k)
+			} else {
+				query.Set(k, v)
+			}
+		}
+		target.RawQuery = query.Encode()
+		links = append(links, "<"+target.String()+">; rel=\""+rel+"\"")
+	}
+
+	if p.isCursor() {
+		add("first", map[string]string{cursorParam: ""})
+		if p.PrevCursor != "" {
+			add("prev", map[string]string{cursorParam: p.PrevCursor})
+		}
+		if p.NextCursor != "" {
+			add("next", map[string]string{cursorParam: p.NextCursor})
+		}
+		return strings.Join(links, ", ")
+	}
+
+	if p.Limit <= 0 {
+		return ""
+	}
+	offsetLink := func(rel string, offset int) {
+		add(rel, map[string]string{
+			offsetParam: strconv.Itoa(offset),
+			limitParam:  strconv.Itoa(p.Limit),
+		})
+	}
+	offsetLink("first", 0)
+	if p.Offset > 0 {
+		prev := p.Offset - p.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		offsetLink("prev", prev)
+	}
+	if p.Offset+p.Limit < p.Total {
+		offsetLink("next", p.Offset+p.Limit)
+	}
+	last := 0
+	if p.Total > 0 {
+		last = (p.Total - 1) / p.Limit * p.Limit
+	}
+	offsetLink("last", last)
+	return strings.Join(links, ", ")
+}
+
+// EncodeCursor encodes provided value (e.g. struct with last seen ID) to
+// opaque cursor that can be sent to client.
+func EncodeCursor(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodeCursor decodes cursor created by EncodeCursor into provided value.
+func DecodeCursor(cursor string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+// EncodeSignedCursor is same as EncodeCursor, except that cursor is signed
+// with HMAC-SHA256 using provided key, so clients can not tamper with it.
+func EncodeSignedCursor(v interface{}, key []byte) (string, error) {
+	cursor, err := EncodeCursor(v)
+	if err != nil {
+		return "", err
+	}
+	return cursor + "." + cursorSignature(cursor, key), nil
+}
+
+// DecodeSignedCursor decodes cursor created by EncodeSignedCursor into
+// provided value. ErrInvalidCursor is returned if signature does not match.
+func DecodeSignedCursor(cursor string, key []byte, v interface{}) error {
+	i := strings.LastIndexByte(cursor, '.')
+	if i < 0 {
+		return ErrInvalidCursor
+	}
+	payload, signature := cursor[:i], cursor[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(cursorSignature(payload, key))) {
+		return ErrInvalidCursor
+	}
+	return DecodeCursor(payload, v)
+}
+
+func cursorSignature(payload string, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
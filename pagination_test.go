@@ -0,0 +1,101 @@
+package jsonresponse
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestPageOffsetMetaAndLinks(t *testing.T) {
+	request := httptest.NewRequest("GET", "/users?limit=10&offset=20&sort=name", nil)
+	recorder := httptest.NewRecorder()
+	Page([]int{1, 2, 3}, PageInfo{Offset: 20, Limit: 10, Total: 45}).OKFor(recorder, request)
+
+	expectedLink := `</users?limit=10&offset=0&sort=name>; rel="first", ` +
+		`</users?limit=10&offset=10&sort=name>; rel="prev", ` +
+		`</users?limit=10&offset=30&sort=name>; rel="next", ` +
+		`</users?limit=10&offset=40&sort=name>; rel="last"`
+	if link := recorder.Header().Get("Link"); link != expectedLink {
+		fmt.Printf("Expected Link %s\nbut got       %s\n", expectedLink, link)
+		t.Fail()
+	}
+
+	unmarshaled := make(map[string]interface{})
+	if err := json.Unmarshal(recorder.Body.Bytes(), &unmarshaled); err != nil {
+		fmt.Println("Failed do unmarshal response!: ", err)
+		t.Fail()
+	}
+	expectedMeta := map[string]interface{}{"offset": 20.0, "limit": 10.0, "total": 45.0}
+	if !reflect.DeepEqual(unmarshaled["meta"], expectedMeta) {
+		fmt.Printf("Expected meta %#v\nbut got       %#v\n", expectedMeta, unmarshaled["meta"])
+		t.Fail()
+	}
+}
+
+func TestPageCursorMetaAndLinks(t *testing.T) {
+	request := httptest.NewRequest("GET", "/users?cursor=abc&limit=2", nil)
+	recorder := httptest.NewRecorder()
+	Page([]int{1, 2}, PageInfo{Limit: 2, Cursor: "abc", NextCursor: "def"}).OKFor(recorder, request)
+
+	expectedLink := `</users?limit=2>; rel="first", </users?cursor=def&limit=2>; rel="next"`
+	if link := recorder.Header().Get("Link"); link != expectedLink {
+		fmt.Printf("Expected Link %s\nbut got       %s\n", expectedLink, link)
+		t.Fail()
+	}
+
+	unmarshaled := make(map[string]interface{})
+	json.Unmarshal(recorder.Body.Bytes(), &unmarshaled)
+	expectedMeta := map[string]interface{}{"limit": 2.0, "next_cursor": "def"}
+	if !reflect.DeepEqual(unmarshaled["meta"], expectedMeta) {
+		fmt.Printf("Expected meta %#v\nbut got       %#v\n", expectedMeta, unmarshaled["meta"])
+		t.Fail()
+	}
+}
+
+func TestPageWithoutRequestHasNoLink(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	Page([]int{}, PageInfo{Limit: 10}).OK(recorder)
+	if _, ok := recorder.HeaderMap["Link"]; ok {
+		fmt.Println("Link header should not be set without request.")
+		t.Fail()
+	}
+}
+
+func TestCursorEncoding(t *testing.T) {
+	type position struct {
+		ID int `json:"id"`
+	}
+	key := []byte("secret")
+
+	cursor, err := EncodeCursor(position{ID: 42})
+	if err != nil {
+		fmt.Println("Failed to encode cursor: ", err)
+		t.Fail()
+	}
+	var decoded position
+	if err := DecodeCursor(cursor, &decoded); err != nil || decoded.ID != 42 {
+		fmt.Println("Cursor not decoded correctly: ", err)
+		t.Fail()
+	}
+
+	signed, err := EncodeSignedCursor(position{ID: 42}, key)
+	if err != nil {
+		fmt.Println("Failed to encode signed cursor: ", err)
+		t.Fail()
+	}
+	decoded = position{}
+	if err := DecodeSignedCursor(signed, key, &decoded); err != nil || decoded.ID != 42 {
+		fmt.Println("Signed cursor not decoded correctly: ", err)
+		t.Fail()
+	}
+	if err := DecodeSignedCursor(signed, []byte("other"), &decoded); err != ErrInvalidCursor {
+		fmt.Println("Signed cursor with wrong key should not be decoded.")
+		t.Fail()
+	}
+	if err := DecodeSignedCursor(cursor, key, &decoded); err != ErrInvalidCursor {
+		fmt.Println("Unsigned cursor should not be accepted as signed.")
+		t.Fail()
+	}
+}
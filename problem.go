@@ -0,0 +1,15 @@
+package jsonresponse
+
+// ProblemContentType is Content-Type of Problem Details responses.
+const ProblemContentType = "application/problem+json"
+
+// Problem is error response in Problem Details format (RFC 7807).
+type Problem struct {
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// RequestID is extension member with ID of request, see RequestIDMiddleware.
+	RequestID string `json:"request_id,omitempty"`
+}
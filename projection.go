@@ -0,0 +1,244 @@
+package jsonresponse
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// maxFieldsDepth limits how deep into nested types valid fields are
+// searched, which protects against recursive types.
+const maxFieldsDepth = 8
+
+// FieldsError is sent to client with HTTP status 400 when fields that do not
+// exist in response are requested via fields query parameter.
+type FieldsError struct {
+	Code    int      `json:"code"`
+	Message string   `json:"message"`
+	Unknown []string `json:"unknown_fields"`
+	Valid   []string `json:"valid_fields"`
+}
+
+// fieldSelection is tree of fields requested by client. Node without
+// children means that whole value is selected.
+type fieldSelection map[string]fieldSelection
+
+// parseFields parses value of fields query parameter, e.g. "id,name,owner.email".
+func parseFields(value string) (selection fieldSelection, paths []string) {
+	selection = fieldSelection{}
+	for _, path := range strings.Split(value, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		paths = append(paths, path)
+		node := selection
+		parts := strings.Split(path, ".")
+		for i, part := range parts {
+			child, exists := node[part]
+			if exists && child == nil {
+				// whole value is already selected
+				break
+			}
+			if i == len(parts)-1 {
+				node[part] = nil
+				break
+			}
+			if !exists {
+				child = fieldSelection{}
+				node[part] = child
+			}
+			node = child
+		}
+	}
+	return selection, paths
+}
+
+// RequestsFields returns true if request selects fields of response data via
+// query parameter set by SetFieldsParameter, in which case data of responses
+// sent for it may not contain all fields of its type.
+func RequestsFields(req *http.Request) bool {
+	_, paths := requestedFields(req, current().fieldsParam)
+	return len(paths) > 0
+}
+
+// selectFields returns fields of data requested in fields query parameter of
+// request, or nil if all fields are requested. If some of requested fields do
+// not exist in data rendered in view, error describing them is returned.
+// Fields are taken from provided snapshot of configuration.
+func selectFields(req *http.Request, data interface{}, view string, s *settings) (fieldSelection, *FieldsError) {
+	selection, paths := requestedFields(req, s.fieldsParam)
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	valid := map[string]bool{}
+	typePaths(reflect.TypeOf(data), "", valid, 0, view, s.keyNaming)
+	if len(unknownPaths(paths, valid)) > 0 {
+		// keys of maps are known only from value
+		treePaths(newConverter(view, s).tree(data), "", valid)
+		if unknown := unknownPaths(paths, valid); len(unknown) > 0 {
+			return nil, newFieldsError(unknown, valid)
+		}
+	}
+	return selection, nil
+}
+
+// filterData returns body produced by transformer, in which data of response
+// is rendered in view, pruned to selected fields and redacted by keys. Envelope
+// of data is not changed. When fast encoder is enabled, filters are applied while data is
+// encoded.
+func (r Response) filterData(body interface{}, describer DataPathDescriber, s *settings) interface{} {
+	// data with its own transformer is document that is not redacted by keys
+	redactKeys := r.transformer == nil && s.redaction.redactsKeys()
+	if body == nil || r.view == "" && r.selection == nil && !redactKeys {
+		return body
+	}
+	var path []string
+	ok := describer != nil
+	if ok {
+		path = describer.DataPath()
+	} else {
+		path, ok = locateData(body, r.Data, s.keyNaming)
+	}
+	wrap := func(data interface{}) interface{} {
+		f := &filtered{data: data, view: r.view, selection: r.selection, redactKeys: redactKeys, s: s}
+		if !sameValue(reflect.ValueOf(data), reflect.ValueOf(r.Data)) {
+			f.original = r.Data
+		}
+		return f
+	}
+	if ok {
+		if filtered, ok := replaceAt(body, path, wrap, s.keyNaming); ok {
+			return filtered
+		}
+	}
+	return wrap(body)
+}
+
+// requestedFields returns fields requested in query parameter with provided
+// name, or nil if there are none.
+func requestedFields(req *http.Request, param string) (fieldSelection, []string) {
+	if param == "" || req == nil || req.URL == nil {
+		return nil, nil
+	}
+	value := req.URL.Query().Get(param)
+	if value == "" {
+		return nil, nil
+	}
+	selection, paths := parseFields(value)
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	return selection, paths
+}
+
+func unknownPaths(paths []string, valid map[string]bool) []string {
+	var unknown []string
+	for _, path := range paths {
+		if !valid[path] {
+			unknown = append(unknown, path)
+		}
+	}
+	return unknown
+}
+
+func newFieldsError(unknown []string, valid map[string]bool) *FieldsError {
+	validList := make([]string, 0, len(valid))
+	for path := range valid {
+		validList = append(validList, path)
+	}
+	sort.Strings(validList)
+	return &FieldsError{
+		Code:    http.StatusBadRequest,
+		Message: "Unknown fields requested: " + strings.Join(unknown, ", "),
+		Unknown: unknown,
+		Valid:   validList,
+	}
+}
+
+// prune removes from tree all fields that are not selected. Selection is
+// applied to each element of arrays. If original tree is provided, fields
+// that do not exist in it (e.g. added by transformer) are kept.
+func prune(tree interface{}, selection fieldSelection, original interface{}) interface{} {
+	switch t := tree.(type) {
+	case *object:
+		o, _ := original.(*object)
+		pruned := newObject(len(selection))
+		for _, k := range t.keys {
+			var originalValue interface{}
+			if o != nil {
+				value, exists := o.values[k]
+				if !exists {
+					pruned.set(k, t.values[k])
+					continue
+				}
+				originalValue = value
+			}
+			child, ok := selection[k]
+			if !ok {
+				continue
+			}
+			if len(child) == 0 {
+				pruned.set(k, t.values[k])
+			} else {
+				pruned.set(k, prune(t.values[k], child, originalValue))
+			}
+		}
+		return pruned
+	case []interface{}:
+		o, _ := original.([]interface{})
+		items := make([]interface{}, len(t))
+		for i, item := range t {
+			var originalItem interface{}
+			if i < len(o) {
+				originalItem = o[i]
+			}
+			items[i] = prune(item, selection, originalItem)
+		}
+		return items
+	}
+	return tree
+}
+
+// typePaths adds paths of all fields of struct types reachable from provided
+// type, including fields that might be omitted from concrete value. Keys of
+// maps are not known from type, so they are found by treePaths. Only fields
+// visible in provided view are included, named with provided naming.
+func typePaths(t reflect.Type, prefix string, paths map[string]bool, depth int, view string, naming keyNaming) {
+	if t == nil || depth > maxFieldsDepth {
+		return
+	}
+	if t.Implements(marshalerType) || t.Implements(textMarshalerType) {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		typePaths(t.Elem(), prefix, paths, depth, view, naming)
+	case reflect.Struct:
+		for _, f := range cachedFields(t, naming) {
+			if !f.visibleIn(view) {
+				continue
+			}
+			path := prefix + f.name
+			paths[path] = true
+			typePaths(f.typ, path+".", paths, depth+1, view, naming)
+		}
+	}
+}
+
+// treePaths adds paths of all fields present in tree.
+func treePaths(tree interface{}, prefix string, paths map[string]bool) {
+	switch t := tree.(type) {
+	case *object:
+		for _, k := range t.keys {
+			path := prefix + k
+			paths[path] = true
+			treePaths(t.values[k], path+".", paths)
+		}
+	case []interface{}:
+		for _, item := range t {
+			treePaths(item, prefix, paths)
+		}
+	}
+}
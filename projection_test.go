@@ -0,0 +1,197 @@
+package jsonresponse
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type projectionOwner struct {
+	Name  string `json:"name"`
+	Email string `json:"email,omitempty"`
+}
+
+type projectionItem struct {
+	ID    int              `json:"id"`
+	Name  string           `json:"name"`
+	Owner *projectionOwner `json:"owner,omitempty"`
+	Tags  []string         `json:"tags"`
+}
+
+type embeddedBase struct {
+	ID      int `json:"id"`
+	Created time.Time
+}
+
+type treeFixture struct {
+	embeddedBase
+	Name     string           `json:"name"`
+	Count    int64            `json:"count,string"`
+	Skipped  string           `json:"-"`
+	Empty    string           `json:",omitempty"`
+	Labels   map[string]int   `json:"labels"`
+	Raw      json.RawMessage  `json:"raw"`
+	Bytes    []byte           `json:"bytes"`
+	Nested   *projectionOwner `json:"nested"`
+	Any      interface{}      `json:"any"`
+	Keyed    map[int]string   `json:"keyed"`
+	Array    [2]bool          `json:"array"`
+	unexport string
+}
+
+func TestTreeSerializesSameAsEncodingJSON(t *testing.T) {
+	for _, v := range []interface{}{
+		nil,
+		42,
+		"<html>",
+		[]int{1, 2, 3},
+		map[string]interface{}{"b": 1, "a": []interface{}{true, nil}},
+		projectionItem{ID: 1, Name: "foo", Tags: []string{"x"}},
+		treeFixture{
+			embeddedBase: embeddedBase{ID: 7, Created: time.Date(2017, 4, 18, 0, 0, 0, 0, time.UTC)},
+			Name:         "bar",
+			Count:        12,
+			Labels:       map[string]int{"z": 1, "a": 2},
+			Raw:          json.RawMessage(`{"x": 1}`),
+			Bytes:        []byte("bytes"),
+			Any:          projectionOwner{Name: "any"},
+			Keyed:        map[int]string{2: "two", 10: "ten"},
+		},
+	} {
+		expected, err := json.Marshal(v)
+		if err != nil {
+			fmt.Println("Failed to marshal value: ", err)
+			t.Fail()
+		}
+		got, err := json.Marshal(toTree(v, current()))
+		if err != nil {
+			fmt.Println("Failed to marshal tree: ", err)
+			t.Fail()
+		}
+		if string(got) != string(expected) {
+			fmt.Printf("Expected %s\nbut got  %s\n", expected, got)
+			t.Fail()
+		}
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	selection, paths := parseFields("id, owner.email,,owner")
+	expected := fieldSelection{"id": nil, "owner": nil}
+	if !reflect.DeepEqual(selection, expected) {
+		fmt.Printf("Expected %#v\nbut got  %#v\n", expected, selection)
+		t.Fail()
+	}
+	if !reflect.DeepEqual(paths, []string{"id", "owner.email", "owner"}) {
+		fmt.Printf("Unexpected paths: %#v\n", paths)
+		t.Fail()
+	}
+}
+
+func TestFieldsProjection(t *testing.T) {
+	SetIndent(false)
+	items := []projectionItem{
+		{ID: 1, Name: "first", Owner: &projectionOwner{Name: "owner", Email: "owner@example.com"}},
+		{ID: 2, Name: "second"},
+	}
+	request := httptest.NewRequest("GET", "/items?fields=id,owner.email", nil)
+	recorder := httptest.NewRecorder()
+	New(items).OKFor(recorder, request)
+
+	expected := `{"data":[{"id":1,"owner":{"email":"owner@example.com"}},{"id":2}]}`
+	if got := strings.TrimSpace(recorder.Body.String()); got != expected {
+		fmt.Printf("Expected %s\nbut got  %s\n", expected, got)
+		t.Fail()
+	}
+}
+
+func TestFieldsProjectionUnknownField(t *testing.T) {
+	request := httptest.NewRequest("GET", "/items?fields=id,password", nil)
+	recorder := httptest.NewRecorder()
+	New(projectionItem{ID: 1}).OKFor(recorder, request)
+
+	if recorder.Code != http.StatusBadRequest {
+		fmt.Printf("HTTP code did not match, got %d, expected: %d\n", recorder.Code, http.StatusBadRequest)
+		t.Fail()
+	}
+	var body struct {
+		Data FieldsError `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		fmt.Println("Failed do unmarshal response!: ", err)
+		t.Fail()
+	}
+	if !reflect.DeepEqual(body.Data.Unknown, []string{"password"}) {
+		fmt.Printf("Unexpected unknown fields: %#v\n", body.Data.Unknown)
+		t.Fail()
+	}
+	expectedValid := []string{"id", "name", "owner", "owner.email", "owner.name", "tags"}
+	if !reflect.DeepEqual(body.Data.Valid, expectedValid) {
+		fmt.Printf("Expected valid fields %#v\nbut got              %#v\n", expectedValid, body.Data.Valid)
+		t.Fail()
+	}
+}
+
+func TestFieldsProjectionDisabled(t *testing.T) {
+	SetIndent(false)
+	SetFieldsParameter("")
+	defer SetFieldsParameter("fields")
+
+	request := httptest.NewRequest("GET", "/items?fields=id", nil)
+	recorder := httptest.NewRecorder()
+	New(map[string]int{"id": 1, "count": 2}).OKFor(recorder, request)
+	expected := `{"data":{"count":2,"id":1}}`
+	if got := strings.TrimSpace(recorder.Body.String()); got != expected {
+		fmt.Printf("Expected %s\nbut got  %s\n", expected, got)
+		t.Fail()
+	}
+}
+
+func TestRequestsFields(t *testing.T) {
+	for path, expected := range map[string]bool{"/items?fields=id": true, "/items?fields=": false, "/items": false} {
+		if got := RequestsFields(httptest.NewRequest("GET", path, nil)); got != expected {
+			fmt.Printf("%s: expected %v, got %v\n", path, expected, got)
+			t.Fail()
+		}
+	}
+	SetFieldsParameter("")
+	defer SetFieldsParameter("fields")
+	if RequestsFields(httptest.NewRequest("GET", "/items?fields=id", nil)) {
+		fmt.Println("Expected no fields when projection is disabled")
+		t.Fail()
+	}
+}
+
+func TestFieldsProjectionKeepsFieldsOfTransformer(t *testing.T) {
+	SetIndent(false)
+	defer ResetTransformer()
+	defer SetFastEncoder(false)
+
+	for _, fast := range []bool{false, true} {
+		SetFastEncoder(fast)
+		for transformer, expected := range map[string]string{
+			"passthrough": `{"message":"nope","request_id":"abc"}`,
+			"envelope":    `{"data":{"message":"nope","request_id":"abc"}}`,
+		} {
+			if transformer == "passthrough" {
+				SetRequestTransformer(RequestIDInBody(AdaptTransformer(PassthroughTransformer)))
+			} else {
+				SetEnvelope(Envelope{Transformer: RequestIDInBody(AdaptTransformer(defaultTransformer)), Path: []string{"data"}})
+			}
+			request := httptest.NewRequest("GET", "/items?fields=message", nil)
+			request = request.WithContext(ContextWithRequestID(request.Context(), "abc"))
+			recorder := httptest.NewRecorder()
+			New(MessageResponse{Code: 404, Message: "nope"}).NotFoundFor(recorder, request)
+
+			if got := strings.TrimSpace(recorder.Body.String()); got != expected {
+				fmt.Printf("Fast encoder %v, %s: expected %s\nbut got  %s\n", fast, transformer, expected, got)
+				t.Fail()
+			}
+		}
+	}
+}
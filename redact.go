@@ -0,0 +1,111 @@
+package jsonresponse
+
+import "strings"
+
+// RedactedValue replaces values of redacted fields in responses.
+const RedactedValue = "[REDACTED]"
+
+// RedactionMode defines what happens with redacted fields.
+type RedactionMode int
+
+const (
+	// RedactReplace replaces value of redacted field with RedactedValue.
+	RedactReplace RedactionMode = iota
+	// RedactDrop removes redacted field from response.
+	RedactDrop
+)
+
+// SetRedactedKeys sets patterns of keys that are redacted from all responses,
+// in addition to struct fields tagged with `jsonresponse:"redact"`. Patterns
+// are matched against keys of JSON objects on any level of data of response,
+// ignoring case, and can contain "*" which matches any sequence of characters
+// (e.g. "*token*"). Envelope produced by transformer and data that provides its
+// own transformer (see TransformerProvider) are not redacted by patterns.
+func SetRedactedKeys(patterns ...string) {
+	keys := make([]string, len(patterns))
+	for i, p := range patterns {
+		keys[i] = strings.ToLower(p)
+	}
+	update(func(s *settings) {
+		s.redaction.keys = keys
+	})
+}
+
+// SetRedactionMode sets whether redacted fields are replaced by RedactedValue
+// (default) or dropped from response.
+func SetRedactionMode(mode RedactionMode) {
+	update(func(s *settings) {
+		s.redaction.drop = mode == RedactDrop
+	})
+}
+
+// DisableRedaction turns redaction off, which can be useful in tests that
+// check values of sensitive fields. It has effect only in builds with
+// jsonresponse_debug build tag (e.g. go test -tags jsonresponse_debug),
+// otherwise it is ignored, so redaction can not be turned off in production.
+func DisableRedaction(flag bool) {
+	update(func(s *settings) {
+		s.redaction.disabled = flag && debugBuild
+	})
+}
+
+// redactionOptions is snapshot of redaction configuration.
+type redactionOptions struct {
+	keys     []string
+	drop     bool
+	disabled bool
+}
+
+// withoutKeys returns options in which only tagged fields are redacted, which
+// are used for values outside of data of response (e.g. envelope).
+func (o redactionOptions) withoutKeys() redactionOptions {
+	o.keys = nil
+	return o
+}
+
+// redactsKeys returns true if keys are redacted by patterns.
+func (o redactionOptions) redactsKeys() bool {
+	return !o.disabled && len(o.keys) > 0
+}
+
+// redacts returns true if field with provided key has to be redacted.
+func (o redactionOptions) redacts(key string, tagged bool) bool {
+	if o.disabled {
+		return false
+	}
+	if tagged {
+		return true
+	}
+	if len(o.keys) == 0 {
+		return false
+	}
+	key = strings.ToLower(key)
+	for _, pattern := range o.keys {
+		if matchKey(pattern, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchKey matches key against pattern in which "*" matches any sequence
+// of characters.
+func matchKey(pattern, key string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == key
+	}
+	if !strings.HasPrefix(key, parts[0]) {
+		return false
+	}
+	key = key[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(key, part)
+		if i < 0 {
+			return false
+		}
+		key = key[i+len(part):]
+	}
+	return strings.HasSuffix(key, last) && len(key) >= len(last)
+}
@@ -0,0 +1,6 @@
+//go:build jsonresponse_debug
+
+package jsonresponse
+
+// debugBuild allows turning redaction off via DisableRedaction.
+const debugBuild = true
@@ -0,0 +1,23 @@
+//go:build jsonresponse_debug
+
+package jsonresponse
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDisableRedactionInDebugBuild(t *testing.T) {
+	DisableRedaction(true)
+	defer DisableRedaction(false)
+
+	recorder := httptest.NewRecorder()
+	New(redactedUser{Name: "foo", PasswordHash: "hash"}).OK(recorder)
+	expected := `{"data":{"name":"foo","password_hash":"hash"}}`
+	if got := strings.TrimSpace(recorder.Body.String()); got != expected {
+		fmt.Printf("Expected %s\nbut got  %s\n", expected, got)
+		t.Fail()
+	}
+}
@@ -0,0 +1,135 @@
+package jsonresponse
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type redactedUser struct {
+	Name         string            `json:"name"`
+	PasswordHash string            `json:"password_hash" jsonresponse:"redact"`
+	Profile      *redactedProfile  `json:"profile,omitempty"`
+	Extra        map[string]string `json:"extra,omitempty"`
+}
+
+type redactedProfile struct {
+	Bio    string `json:"bio"`
+	Secret string `json:"secret" jsonresponse:"redact"`
+}
+
+func TestRedactionByTag(t *testing.T) {
+	SetIndent(false)
+	recorder := httptest.NewRecorder()
+	New([]redactedUser{
+		{Name: "foo", PasswordHash: "hash", Profile: &redactedProfile{Bio: "bio", Secret: "s3cr3t"}},
+	}).OK(recorder)
+
+	expected := `{"data":[{"name":"foo","password_hash":"[REDACTED]","profile":{"bio":"bio","secret":"[REDACTED]"}}]}`
+	if got := strings.TrimSpace(recorder.Body.String()); got != expected {
+		fmt.Printf("Expected %s\nbut got  %s\n", expected, got)
+		t.Fail()
+	}
+}
+
+func TestRedactionByKeyPattern(t *testing.T) {
+	SetIndent(false)
+	SetRedactedKeys("*token*", "Password")
+	SetRedactionMode(RedactDrop)
+	defer SetRedactedKeys()
+	defer SetRedactionMode(RedactReplace)
+
+	recorder := httptest.NewRecorder()
+	New(map[string]interface{}{
+		"user": redactedUser{
+			Name:         "foo",
+			PasswordHash: "hash",
+			Extra:        map[string]string{"access_token": "abc", "password": "pass", "color": "red"},
+		},
+		"refreshToken": "def",
+	}).OK(recorder)
+
+	expected := `{"data":{"user":{"name":"foo","extra":{"color":"red"}}}}`
+	if got := strings.TrimSpace(recorder.Body.String()); got != expected {
+		fmt.Printf("Expected %s\nbut got  %s\n", expected, got)
+		t.Fail()
+	}
+}
+
+// redactedDocument is sent with its own transformer, like OpenAPI document.
+type redactedDocument struct {
+	Password string `json:"password"`
+}
+
+func (d redactedDocument) Transformer() RequestTransformer {
+	return AdaptTransformer(PassthroughTransformer)
+}
+
+func TestRedactionByKeyPatternOnlyInData(t *testing.T) {
+	SetIndent(false)
+	SetRedactedKeys("*code*", "password")
+	defer SetRedactedKeys()
+	SetEnvelope(MessageCodeTransformer("data", "code"))
+	defer ResetTransformer()
+	defer SetFastEncoder(false)
+
+	for _, fast := range []bool{false, true} {
+		SetFastEncoder(fast)
+		recorder := httptest.NewRecorder()
+		New(map[string]string{"area_code": "011", "city": "Belgrade"}).OK(recorder)
+		expected := `{"code":200,"data":{"area_code":"[REDACTED]","city":"Belgrade"}}`
+		if got := strings.TrimSpace(recorder.Body.String()); got != expected {
+			fmt.Printf("Fast encoder %v: expected %s\nbut got  %s\n", fast, expected, got)
+			t.Fail()
+		}
+
+		recorder = httptest.NewRecorder()
+		New(redactedDocument{Password: "format"}).OK(recorder)
+		expected = `{"password":"format"}`
+		if got := strings.TrimSpace(recorder.Body.String()); got != expected {
+			fmt.Printf("Fast encoder %v: expected %s\nbut got  %s\n", fast, expected, got)
+			t.Fail()
+		}
+	}
+}
+
+func TestRedactionInGenericRespond(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	OK(recorder, redactedUser{Name: "foo", PasswordHash: "hash"})
+	expected := `{"name":"foo","password_hash":"[REDACTED]"}`
+	if got := strings.TrimSpace(recorder.Body.String()); got != expected {
+		fmt.Printf("Expected %s\nbut got  %s\n", expected, got)
+		t.Fail()
+	}
+}
+
+func TestDisableRedactionIgnoredInReleaseBuild(t *testing.T) {
+	if debugBuild {
+		t.Skip("redaction can be disabled in debug build")
+	}
+	DisableRedaction(true)
+	defer DisableRedaction(false)
+
+	recorder := httptest.NewRecorder()
+	New(redactedUser{Name: "foo", PasswordHash: "hash"}).OK(recorder)
+	if strings.Contains(recorder.Body.String(), `:"hash"`) {
+		fmt.Println("Redaction should not be disabled in release build.")
+		t.Fail()
+	}
+}
+
+func TestMatchKey(t *testing.T) {
+	for pattern, keys := range map[string]map[string]bool{
+		"password": {"password": true, "password_hash": false},
+		"*token*":  {"token": true, "access_token": true, "tokens": true, "toke": false},
+		"api*key":  {"apikey": true, "api_key": true, "api_keys": false, "key": false},
+	} {
+		for key, expected := range keys {
+			if matchKey(pattern, key) != expected {
+				fmt.Printf("Pattern %q matching %q should be %v\n", pattern, key, expected)
+				t.Fail()
+			}
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package jsonresponse
+
+import "net/http"
+
+// Request aware versions of status helpers. They pass request to transformer,
+// so transformers set via SetRequestTransformer can use it.
+
+// 1xx
+
+// ContinueFor sends response to client with HTTP status 100 for request.
+// This means that server has received the request headers and that the client
+// should proceed to send the request body
+func (r Response) ContinueFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusContinue)
+}
+
+// SwitchingProtocolsFor sends response to client with HTTP status 101 for request.
+// This means the requester has asked the server to switch protocols and the
+// server is acknowledging that it will do so.
+func (r Response) SwitchingProtocolsFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusSwitchingProtocols)
+}
+
+// 2xx
+
+// OKFor sends response to client with HTTP status 200 for request.
+// Standard response for successful HTTP requests.
+func (r Response) OKFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusOK)
+}
+
+// CreatedFor sends response to client with HTTP status 201 for request.
+// The request has been fulfilled and resulted in a new resource being created.
+func (r Response) CreatedFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusCreated)
+}
+
+// AcceptedFor sends response to client with HTTP status 202 for request.
+// The request has been accepted for processing, but the processing has not
+// been completed.
+func (r Response) AcceptedFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusAccepted)
+}
+
+// NonAuthoritativeInfoFor sends response to client with HTTP status 203 for request.
+// The server successfully processed the request, but is returning information
+// that may be from another source.
+func (r Response) NonAuthoritativeInfoFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusNonAuthoritativeInfo)
+}
+
+// NoContentFor sends response to client with HTTP status 204 for request.
+// The server successfully processed the request, but is not returning any content.
+func (r Response) NoContentFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusNoContent)
+}
+
+// ResetContentFor sends response to client with HTTP status 205 for request.
+// The server successfully processed the request, but is not returning any content.
+// Unlike a NoContent response, this response requires that the requester reset
+// the document view.
+func (r Response) ResetContentFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusResetContent)
+}
+
+// PartialContentFor sends response to client with HTTP status 206 for request.
+// The server is delivering only part of the resource (byte serving) due to a
+// range header sent by the client.
+func (r Response) PartialContentFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusPartialContent)
+}
+
+// 3xx
+
+// MultipleChoicesFor sends response to client with HTTP status 300 for request.
+// Indicates multiple options for the resource that the client may follow.
+func (r Response) MultipleChoicesFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusMultipleChoices)
+}
+
+// MovedPermanentlyFor sends response to client with HTTP status 301 for request.
+// This and all future requests should be directed to the given URI.
+func (r Response) MovedPermanentlyFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusMovedPermanently)
+}
+
+// FoundFor sends response to client with HTTP status 302 for request.
+func (r Response) FoundFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusFound)
+}
+
+// SeeOtherFor sends response to client with HTTP status 303 for request.
+// The response to the request can be found under another URI using a GET method.
+func (r Response) SeeOtherFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusSeeOther)
+}
+
+// NotModifiedFor sends response to client with HTTP status 304 for request.
+// Indicates that the resource has not been modified since the version specified
+// by the request headers If-Modified-Since or If-None-Match.
+func (r Response) NotModifiedFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusNotModified)
+}
+
+// UseProxyFor sends response to client with HTTP status 305 for request.
+// The requested resource is only available through a proxy, whose address is
+// provided in the response.
+func (r Response) UseProxyFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusUseProxy)
+}
+
+// TemporaryRedirectFor sends response to client with HTTP status 307 for request.
+// In this case, the request should be repeated with another URI; however,
+// future requests should still use the original URI.
+func (r Response) TemporaryRedirectFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusTemporaryRedirect)
+}
+
+// 4xx
+
+// BadRequestFor sends response to client with HTTP status 400 for request.
+// The server cannot or will not process the request due to something that is
+// perceived to be a client error
+func (r Response) BadRequestFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusBadRequest)
+}
+
+// UnauthorizedFor sends response to client with HTTP status 401 for request.
+// Similar to 403 Forbidden, but specifically for use when authentication
+// is required and has failed or has not yet been provided.
+func (r Response) UnauthorizedFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusUnauthorized)
+}
+
+// PaymentRequiredFor sends response to client with HTTP status 402 for request.
+// Reserved for future use.
+func (r Response) PaymentRequiredFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusPaymentRequired)
+}
+
+// ForbiddenFor sends response to client with HTTP status 403 for request.
+// The request was a valid request, but the server is refusing to respond to it.
+// Unlike a 401 Unauthorized response, authenticating will make no difference.
+func (r Response) ForbiddenFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusForbidden)
+}
+
+// NotFoundFor sends response to client with HTTP status 404 for request.
+// The requested resource could not be found but may be available again in the future.
+func (r Response) NotFoundFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusNotFound)
+}
+
+// MethodNotAllowedFor sends response to client with HTTP status 405 for request.
+// A request was made of a resource using a request method not supported by that
+// resource; for example, using GET on a form which requires data to be presented
+// via POST, or using PUT on a read-only resource.
+func (r Response) MethodNotAllowedFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusMethodNotAllowed)
+}
+
+// NotAcceptableFor sends response to client with HTTP status 406 for request.
+// The requested resource is only capable of generating content not acceptable
+// according to the Accept headers sent in the request.
+func (r Response) NotAcceptableFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusNotAcceptable)
+}
+
+// ProxyAuthRequiredFor sends response to client with HTTP status 407 for request.
+// The client must first authenticate itself with the proxy.
+func (r Response) ProxyAuthRequiredFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusProxyAuthRequired)
+}
+
+// RequestTimeoutFor sends response to client with HTTP status 408 for request.
+// The server timed out waiting for the request.
+func (r Response) RequestTimeoutFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusRequestTimeout)
+}
+
+// ConflictFor sends response to client with HTTP status 409 for request.
+// Indicates that the request could not be processed because of conflict
+// in the request.
+func (r Response) ConflictFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusConflict)
+}
+
+// GoneFor sends response to client with HTTP status 410 for request.
+// Indicates that the resource requested is no longer available and will not
+// be available again.
+func (r Response) GoneFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusGone)
+}
+
+// LengthRequiredFor sends response to client with HTTP status 411 for request.
+// The request did not specify the length of its content, which is
+// required by the requested resource.
+func (r Response) LengthRequiredFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusLengthRequired)
+}
+
+// PreconditionFailedFor sends response to client with HTTP status 412 for request.
+// The server does not meet one of the preconditions that the requester put
+// on the request.
+func (r Response) PreconditionFailedFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusPreconditionFailed)
+}
+
+// RequestEntityTooLargeFor sends response to client with HTTP status 413 for request.
+// The request is larger than the server is willing or able to process.
+func (r Response) RequestEntityTooLargeFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusRequestEntityTooLarge)
+}
+
+// RequestURITooLongFor sends response to client with HTTP status 414 for request.
+// The URI provided was too long for the server to process.
+func (r Response) RequestURITooLongFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusRequestURITooLong)
+}
+
+// UnsupportedMediaTypeFor sends response to client with HTTP status 415 for request.
+// The request entity has a media type which the server or resource does
+// not support.
+func (r Response) UnsupportedMediaTypeFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusUnsupportedMediaType)
+}
+
+// RequestedRangeNotSatisfiableFor sends response to client with HTTP status 416 for request.
+// The client has asked for a portion of the file (byte serving), but the
+// server cannot supply that portion.
+func (r Response) RequestedRangeNotSatisfiableFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusRequestedRangeNotSatisfiable)
+}
+
+// ExpectationFailedFor sends response to client with HTTP status 417 for request.
+// The server cannot meet the requirements of the Expect request-header field.
+func (r Response) ExpectationFailedFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusExpectationFailed)
+}
+
+// TeapotFor sends response to client with HTTP status 418 for request.
+// This code should be returned by tea pots requested to brew coffee.
+func (r Response) TeapotFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusTeapot)
+}
+
+// 5xx
+
+// InternalServerErrorFor sends response to client with HTTP status 500 for request.
+// A generic error message, given when an unexpected condition was
+// encountered and no more specific message is suitable.
+func (r Response) InternalServerErrorFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusInternalServerError)
+}
+
+// NotImplementedFor sends response to client with HTTP status 501 for request.
+// The server either does not recognize the request method, or it lacks the
+// ability to fulfill the request.
+func (r Response) NotImplementedFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusNotImplemented)
+}
+
+// BadGatewayFor sends response to client with HTTP status 502 for request.
+// The server was acting as a gateway or proxy and received an invalid
+// response from the upstream server.
+func (r Response) BadGatewayFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusBadGateway)
+}
+
+// ServiceUnavailableFor sends response to client with HTTP status 503 for request.
+// The server is currently unavailable (because it is overloaded or down
+// for maintenance). Generally, this is a temporary state.
+func (r Response) ServiceUnavailableFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusServiceUnavailable)
+}
+
+// GatewayTimeoutFor sends response to client with HTTP status 504 for request.
+// The server was acting as a gateway or proxy and did not receive a timely
+// response from the upstream server.
+func (r Response) GatewayTimeoutFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusGatewayTimeout)
+}
+
+// HTTPVersionNotSupportedFor sends response to client with HTTP status 505 for request.
+// The server does not support the HTTP protocol version used in the request.
+func (r Response) HTTPVersionNotSupportedFor(w http.ResponseWriter, req *http.Request) {
+	r.ResponseFor(w, req, http.StatusHTTPVersionNotSupported)
+}
@@ -0,0 +1,174 @@
+package jsonresponse
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type requestIDKey struct{}
+
+// ContextWithRequestID returns context with provided request ID.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom returns request ID from context, or empty string if there
+// is none (see RequestIDMiddleware).
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestIDMiddleware returns handler that determines ID of each request and
+// stores it in request context, from which transformers can read it via
+// RequestIDFrom. ID is taken from request ID header (see SetRequestIDHeader)
+// if it has at most 128 visible ASCII characters, or from trace ID of W3C
+// traceparent header, otherwise random ID is generated. ID is sent back in
+// response in request ID header, and it is set in every MessageResponse and
+// Problem that is sent via Respond (and functions like NotFound) to writer
+// with request ID header. Writer is passed to handler unchanged.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		header := current().requestIDHeader
+		id := req.Header.Get(header)
+		if !validRequestID(id) {
+			id = traceID(req.Header.Get("traceparent"))
+		}
+		if id == "" {
+			id = newRequestID()
+		}
+		w.Header().Set(header, id)
+		next.ServeHTTP(w, req.WithContext(ContextWithRequestID(req.Context(), id)))
+	})
+}
+
+// maxRequestIDLength is maximal length of request ID accepted from client.
+const maxRequestIDLength = 128
+
+// validRequestID returns true if id is not empty and has at most
+// maxRequestIDLength visible ASCII characters.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestIDMeta wraps transformer, so request ID is added to "meta" object
+// of its result as "request_id" field. Result of wrapped transformer has to be
+// map (like one returned by default transformer), otherwise it is returned
+// unchanged.
+func RequestIDMeta(t RequestTransformer) RequestTransformer {
+	return RequestTransformer(func(req *http.Request, resp Response, httpCode int) (headers map[string]string, result interface{}) {
+		headers, result = t(req, resp, httpCode)
+		if req == nil {
+			return headers, result
+		}
+		if m, ok := result.(map[string]interface{}); ok {
+			if id := RequestIDFrom(req.Context()); id != "" {
+				result = withMeta(resp.metaField(), m, map[string]interface{}{"request_id": id})
+			}
+		}
+		return headers, result
+	})
+}
+
+// RequestIDInBody wraps transformer, so request ID is set in data of response
+// if it is MessageResponse or Problem, before it is passed to transformer.
+func RequestIDInBody(t RequestTransformer) RequestTransformer {
+	return RequestTransformer(func(req *http.Request, resp Response, httpCode int) (headers map[string]string, result interface{}) {
+		if req != nil {
+			resp.Data = withRequestID(resp.Data, RequestIDFrom(req.Context()))
+		}
+		return t(req, resp, httpCode)
+	})
+}
+
+// withRequestID returns copy of MessageResponse or Problem with request ID
+// set, unless it already has one. Other values are returned unchanged.
+func withRequestID(data interface{}, id string) interface{} {
+	if id == "" {
+		return data
+	}
+	switch d := data.(type) {
+	case MessageResponse:
+		if d.RequestID == "" {
+			d.RequestID = id
+		}
+		return d
+	case *MessageResponse:
+		if d != nil && d.RequestID == "" {
+			c := *d
+			c.RequestID = id
+			return &c
+		}
+	case Problem:
+		if d.RequestID == "" {
+			d.RequestID = id
+		}
+		return d
+	case *Problem:
+		if d != nil && d.RequestID == "" {
+			c := *d
+			c.RequestID = id
+			return &c
+		}
+	}
+	return data
+}
+
+// requestIDOf returns request ID from response header with provided name,
+// or empty string.
+func requestIDOf(w http.ResponseWriter, header string) string {
+	if w == nil {
+		return ""
+	}
+	return w.Header().Get(header)
+}
+
+// traceID returns trace ID from W3C traceparent header
+// (version-traceid-parentid-flags), or empty string if header is not valid.
+func traceID(traceparent string) string {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return ""
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return ""
+	}
+	for _, part := range parts[:3] {
+		if !isLowerHex(part) {
+			return ""
+		}
+	}
+	if parts[1] == strings.Repeat("0", 32) || parts[2] == strings.Repeat("0", 16) {
+		return ""
+	}
+	return parts[1]
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns random 128-bit ID in hex.
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("%x", b)
+}
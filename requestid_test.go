@@ -0,0 +1,128 @@
+package jsonresponse
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func serveWithRequestID(handler http.HandlerFunc, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/", nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	recorder := httptest.NewRecorder()
+	RequestIDMiddleware(handler).ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var seen string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFrom(r.Context())
+	}
+	for _, c := range []struct {
+		headers  map[string]string
+		expected string
+	}{
+		{map[string]string{"X-Request-ID": "abc"}, "abc"},
+		{map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}, "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{map[string]string{"X-Request-ID": "abc", "traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}, "abc"},
+		{map[string]string{"X-Request-ID": "a b", "traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}, "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{map[string]string{"X-Request-ID": strings.Repeat("a", 128)}, strings.Repeat("a", 128)},
+	} {
+		recorder := serveWithRequestID(handler, c.headers)
+		if seen != c.expected || recorder.Header().Get("X-Request-ID") != c.expected {
+			fmt.Printf("Expected request ID %s, got %s in context and %s in header\n", c.expected, seen, recorder.Header().Get("X-Request-ID"))
+			t.Fail()
+		}
+	}
+
+	for _, traceparent := range []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+	} {
+		serveWithRequestID(handler, map[string]string{"traceparent": traceparent})
+		if !regexp.MustCompile(`^[0-9a-f]{32}$`).MatchString(seen) || seen == "4bf92f3577b34da6a3ce929d0e0e4736" {
+			fmt.Printf("Expected generated ID for traceparent %q, got %s\n", traceparent, seen)
+			t.Fail()
+		}
+	}
+}
+
+func TestRequestIDMiddlewareInvalidID(t *testing.T) {
+	var seen string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFrom(r.Context())
+	}
+	for _, id := range []string{strings.Repeat("a", 129), "a\tb", "café", "a\x7f"} {
+		recorder := serveWithRequestID(handler, map[string]string{"X-Request-ID": id})
+		if !regexp.MustCompile(`^[0-9a-f]{32}$`).MatchString(seen) || recorder.Header().Get("X-Request-ID") != seen {
+			fmt.Printf("Expected generated ID for %q, got %s\n", id, seen)
+			t.Fail()
+		}
+	}
+}
+
+// hijackableWriter is writer that supports hijacking of connection.
+type hijackableWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w hijackableWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, nil
+}
+
+func TestRequestIDMiddlewareKeepsWriter(t *testing.T) {
+	w := hijackableWriter{httptest.NewRecorder()}
+	RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Hijacker); !ok {
+			fmt.Printf("Expected writer that can be hijacked\n")
+			t.Fail()
+		}
+	})).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+}
+
+func TestRequestIDHeader(t *testing.T) {
+	SetRequestIDHeader("X-Correlation-ID")
+	defer SetRequestIDHeader("X-Request-ID")
+	recorder := serveWithRequestID(func(w http.ResponseWriter, r *http.Request) {}, map[string]string{"X-Correlation-ID": "abc"})
+	if recorder.Header().Get("X-Correlation-ID") != "abc" || recorder.Header().Get("X-Request-ID") != "" {
+		fmt.Printf("Expected request ID in custom header, got %v\n", recorder.Header())
+		t.Fail()
+	}
+}
+
+func TestRequestIDInResponses(t *testing.T) {
+	SetRequestTransformer(RequestIDInBody(RequestIDMeta(AdaptTransformer(defaultTransformer))))
+	defer ResetTransformer()
+
+	for _, c := range []struct {
+		handler  http.HandlerFunc
+		expected string
+	}{
+		{func(w http.ResponseWriter, r *http.Request) { New(1).OKFor(w, r) }, `{"data":1,"meta":{"request_id":"abc"}}`},
+		{func(w http.ResponseWriter, r *http.Request) { NotFound(w, nil) }, `{"code":404,"message":"Not Found","request_id":"abc"}`},
+		{func(w http.ResponseWriter, r *http.Request) { BadRequest(w, Problem{Title: "Invalid"}) }, `{"title":"Invalid","request_id":"abc"}`},
+		{func(w http.ResponseWriter, r *http.Request) { New(MessageResponse{Message: "Gone"}).GoneFor(w, r) },
+			`{"data":{"message":"Gone","request_id":"abc"},"meta":{"request_id":"abc"}}`},
+	} {
+		recorder := serveWithRequestID(c.handler, map[string]string{"X-Request-ID": "abc"})
+		var got, expected interface{}
+		json.Unmarshal(recorder.Body.Bytes(), &got)
+		json.Unmarshal([]byte(c.expected), &expected)
+		if fmt.Sprint(got) != fmt.Sprint(expected) {
+			fmt.Printf("Expected %s, got %s\n", c.expected, recorder.Body.String())
+			t.Fail()
+		}
+	}
+}
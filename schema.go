@@ -0,0 +1,283 @@
+package jsonresponse
+
+import (
+	"net/http"
+	"path"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// SchemaVersion is JSON Schema dialect of schemas returned by Schema.
+const SchemaVersion = "https://json-schema.org/draft/2020-12/schema"
+
+// SchemaDescriber can be implemented by transformers to describe envelope
+// they produce in more detail than path of data (see DataPathDescriber).
+type SchemaDescriber interface {
+	// DescribeSchema returns schema of envelope that contains data with
+	// provided schema.
+	DescribeSchema(data map[string]interface{}) map[string]interface{}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Schema returns JSON Schema (2020-12) of responses with data of same type
+// as v, wrapped in envelope of provided transformer. Transformer can be
+// SchemaDescriber, Envelope or other DataPathDescriber, and if it is nil,
+// transformer used for responses with status 200 is used. Envelope{} describes
+// data without envelope.
+//
+// Envelope of transformer that is not SchemaDescriber is described by path of
+// data that it declares, as nested objects with required field that contains
+// data, which can have other fields too. Transformers are never called, so
+// envelope of transformer that declares neither is not described and any
+// value is allowed.
+//
+// Numbers are described as they are encoded with policy set via
+// SetNumberPolicy, and fields of data with keys redacted via SetRedactedKeys
+// are described as redacted, so Schema should be called after package is
+// configured.
+func Schema(v interface{}, transformer interface{}) map[string]interface{} {
+	g := NewSchemaGenerator()
+	document := map[string]interface{}{"$schema": SchemaVersion}
+	for k, v := range g.Schema(v, transformer) {
+		document[k] = v
+	}
+	if defs := g.Definitions(); len(defs) > 0 {
+		document["$defs"] = defs
+	}
+	return document
+}
+
+// SchemaGenerator describes multiple responses with shared definitions of
+// named types, so each type is defined once. Types with same name from
+// different packages are defined under names qualified with package name.
+type SchemaGenerator struct {
+	g *schemaGenerator
+}
+
+// NewSchemaGenerator returns generator that uses current configuration of
+// package, see Schema.
+func NewSchemaGenerator() *SchemaGenerator {
+	s := current()
+	return &SchemaGenerator{g: &schemaGenerator{
+		defs:     map[string]interface{}{},
+		names:    map[reflect.Type]string{},
+		settings: s,
+		numbers:  s.numberPolicy,
+	}}
+}
+
+// Schema returns schema like package level Schema, but without "$schema" and
+// "$defs" keys. References point to definitions returned by Definitions.
+func (s *SchemaGenerator) Schema(v interface{}, transformer interface{}) map[string]interface{} {
+	return s.g.schema(v, transformer)
+}
+
+// Definitions returns definitions of all named types described so far.
+func (s *SchemaGenerator) Definitions() map[string]interface{} {
+	return s.g.defs
+}
+
+type schemaGenerator struct {
+	defs  map[string]interface{}
+	names map[reflect.Type]string
+	// settings of package when generator was created
+	settings *settings
+	// numbers is policy of encoding numbers, see NumberPolicy
+	numbers NumberPolicy
+	// redaction of fields, which are described as redacted
+	redaction redactionOptions
+}
+
+func (g *schemaGenerator) schema(v interface{}, transformer interface{}) map[string]interface{} {
+	if transformer == nil {
+		entry := g.settings.transformerFor(http.StatusOK)
+		if entry.transform == nil {
+			transformer = Envelope{}
+		} else if entry.describer != nil {
+			transformer = entry.describer
+		}
+	}
+	var envelope func(data map[string]interface{}) map[string]interface{}
+	switch t := transformer.(type) {
+	case SchemaDescriber:
+		envelope = t.DescribeSchema
+	case DataPathDescriber:
+		path := t.DataPath()
+		envelope = func(data map[string]interface{}) map[string]interface{} {
+			return pathSchema(path, data)
+		}
+	default:
+		// envelope of transformer that does not declare it can not be
+		// described
+		return map[string]interface{}{}
+	}
+
+	g.redaction = g.settings.redaction
+	data := g.typeSchema(reflect.TypeOf(v))
+	// keys are redacted only in data, not in envelope
+	g.redaction = g.redaction.withoutKeys()
+	return envelope(data)
+}
+
+// pathSchema describes envelope that places data under provided path of keys.
+func pathSchema(path []string, data map[string]interface{}) map[string]interface{} {
+	schema := data
+	for i := len(path) - 1; i >= 0; i-- {
+		schema = map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{path[i]: schema},
+			"required":   []string{path[i]},
+		}
+	}
+	return schema
+}
+
+// typeSchema describes values of provided type, as encoded by encoding/json.
+func (g *schemaGenerator) typeSchema(t reflect.Type) map[string]interface{} {
+	if t == nil {
+		return map[string]interface{}{}
+	}
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	if t == numberType {
+		if g.numbers.BigIntsAsStrings {
+			return map[string]interface{}{"type": []string{"number", "string"}}
+		}
+		return map[string]interface{}{"type": "number"}
+	}
+	if t.Kind() == reflect.Ptr {
+		return nullable(g.typeSchema(t.Elem()))
+	}
+	if t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType) {
+		// encoding is known only to type itself
+		return map[string]interface{}{}
+	}
+	if t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) {
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		// integers that JavaScript can not represent are encoded as strings
+		if g.numbers.BigIntsAsStrings {
+			return map[string]interface{}{"type": []string{"integer", "string"}}
+		}
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		switch g.numbers.NonFinite {
+		case NonFiniteNull:
+			return map[string]interface{}{"type": []string{"number", "null"}}
+		case NonFiniteString:
+			return map[string]interface{}{"anyOf": []interface{}{
+				map[string]interface{}{"type": "number"},
+				map[string]interface{}{"enum": []string{"NaN", "Infinity", "-Infinity"}},
+			}}
+		}
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return nullable(map[string]interface{}{"type": "string", "contentEncoding": "base64"})
+		}
+		return nullable(map[string]interface{}{"type": "array", "items": g.typeSchema(t.Elem())})
+	case reflect.Array:
+		return map[string]interface{}{
+			"type":     "array",
+			"items":    g.typeSchema(t.Elem()),
+			"minItems": t.Len(),
+			"maxItems": t.Len(),
+		}
+	case reflect.Map:
+		values := g.typeSchema(t.Elem())
+		// any key can be redacted
+		if g.redaction.redactsKeys() && !g.redaction.drop {
+			values = map[string]interface{}{"anyOf": []interface{}{values, map[string]interface{}{"const": RedactedValue}}}
+		}
+		return nullable(map[string]interface{}{"type": "object", "additionalProperties": values})
+	case reflect.Struct:
+		return g.structSchema(t)
+	}
+	// interface{} can hold any value
+	return map[string]interface{}{}
+}
+
+// structSchema adds schema of struct to definitions and returns reference
+// to it, so recursive types can be described.
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	if t.Name() == "" {
+		return g.fieldsSchema(t)
+	}
+	name, ok := g.names[t]
+	if !ok {
+		name = t.Name()
+		if g.defs[name] != nil && t.PkgPath() != "" {
+			name = path.Base(t.PkgPath()) + "." + t.Name()
+		}
+		base := name
+		for i := 2; g.defs[name] != nil; i++ {
+			name = base + strconv.Itoa(i)
+		}
+		// name is reserved before fields are described, since they can refer to it
+		g.names[t] = name
+		g.defs[name] = map[string]interface{}{}
+		g.defs[name] = g.fieldsSchema(t)
+	}
+	return map[string]interface{}{"$ref": "#/$defs/" + name}
+}
+
+func (g *schemaGenerator) fieldsSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	for _, f := range cachedFields(t, g.settings.keyNaming) {
+		redacted := g.redaction.redacts(f.name, f.redact)
+		if redacted && g.redaction.drop {
+			continue
+		}
+		var schema map[string]interface{}
+		switch {
+		case redacted:
+			schema = map[string]interface{}{"const": RedactedValue}
+		case f.quoted:
+			schema = map[string]interface{}{"type": "string"}
+		default:
+			schema = g.typeSchema(typeByIndex(t, f.index))
+		}
+		properties[f.name] = schema
+		// fields with views are not included in all views
+		if !f.omitEmpty && !f.omitZero && !redacted && len(f.views) == 0 {
+			required = append(required, f.name)
+		}
+	}
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// nullable allows null in addition to values described by schema.
+func nullable(schema map[string]interface{}) map[string]interface{} {
+	if ref, ok := schema["$ref"]; ok {
+		return map[string]interface{}{"anyOf": []interface{}{
+			map[string]interface{}{"$ref": ref},
+			map[string]interface{}{"type": "null"},
+		}}
+	}
+	// schema without type already allows null
+	if t, ok := schema["type"].(string); ok {
+		schema["type"] = []string{t, "null"}
+	}
+	return schema
+}
@@ -0,0 +1,139 @@
+package jsonresponse
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type schemaUser struct {
+	ID       int            `json:"id"`
+	Name     string         `json:"name,omitempty"`
+	Email    string         `json:"email" jsonresponse:"view=admin"`
+	Password string         `json:"password" jsonresponse:"redact"`
+	Created  time.Time      `json:"created"`
+	Manager  *schemaUser    `json:"manager"`
+	Tags     []string       `json:"tags"`
+	Score    float64        `json:"score,string"`
+	Extra    map[string]int `json:"extra,omitempty"`
+}
+
+type staticEnvelope struct{}
+
+func (staticEnvelope) DescribeSchema(data map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"type": "object", "properties": map[string]interface{}{"result": data}}
+}
+
+func schemaJSON(schema map[string]interface{}) string {
+	b, _ := json.Marshal(schema)
+	return string(b)
+}
+
+func TestSchemaDefaultTransformer(t *testing.T) {
+	expected := `{"$defs":{"schemaUser":{"additionalProperties":false,"properties":{` +
+		`"created":{"format":"date-time","type":"string"},` +
+		`"email":{"type":"string"},` +
+		`"extra":{"additionalProperties":{"type":"integer"},"type":["object","null"]},` +
+		`"id":{"type":"integer"},` +
+		`"manager":{"anyOf":[{"$ref":"#/$defs/schemaUser"},{"type":"null"}]},` +
+		`"name":{"type":"string"},` +
+		`"password":{"const":"[REDACTED]"},` +
+		`"score":{"type":"string"},` +
+		`"tags":{"items":{"type":"string"},"type":["array","null"]}},` +
+		`"required":["id","created","manager","tags","score"],"type":"object"}},` +
+		`"$schema":"https://json-schema.org/draft/2020-12/schema",` +
+		`"properties":{` +
+		`"data":{"items":{"$ref":"#/$defs/schemaUser"},"type":["array","null"]}},` +
+		`"required":["data"],"type":"object"}`
+	if got := schemaJSON(Schema([]schemaUser{}, nil)); got != expected {
+		fmt.Printf("Expected %s\nbut got  %s\n", expected, got)
+		t.Fail()
+	}
+}
+
+func TestSchemaMessageCodeTransformer(t *testing.T) {
+	expected := `{"$schema":"https://json-schema.org/draft/2020-12/schema","properties":{` +
+		`"payload":{"type":"boolean"}},"required":["payload"],"type":"object"}`
+	if got := schemaJSON(Schema(true, MessageCodeTransformer("payload", "code"))); got != expected {
+		fmt.Printf("Expected %s\nbut got  %s\n", expected, got)
+		t.Fail()
+	}
+}
+
+func TestSchemaEnvelopePath(t *testing.T) {
+	// transformer is never called, envelope is described by its path
+	transformer := func(r *http.Request, resp Response, httpCode int) (map[string]string, interface{}) {
+		panic("transformer called")
+	}
+	expected := `{"$schema":"https://json-schema.org/draft/2020-12/schema","properties":{` +
+		`"result":{"properties":{"items":{"type":"boolean"}},"required":["items"],"type":"object"}},` +
+		`"required":["result"],"type":"object"}`
+	if got := schemaJSON(Schema(true, Envelope{Transformer: transformer, Path: []string{"result", "items"}})); got != expected {
+		fmt.Printf("Expected %s\nbut got  %s\n", expected, got)
+		t.Fail()
+	}
+
+	expected = `{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"boolean"}`
+	if got := schemaJSON(Schema(true, Envelope{})); got != expected {
+		fmt.Printf("Expected %s\nbut got  %s\n", expected, got)
+		t.Fail()
+	}
+
+	SetEnvelope(Envelope{Transformer: transformer, Path: []string{"result"}})
+	defer ResetTransformer()
+	expected = `{"$schema":"https://json-schema.org/draft/2020-12/schema","properties":{` +
+		`"result":{"type":"boolean"}},"required":["result"],"type":"object"}`
+	if got := schemaJSON(Schema(true, nil)); got != expected {
+		fmt.Printf("Expected %s\nbut got  %s\n", expected, got)
+		t.Fail()
+	}
+}
+
+func TestSchemaDescriber(t *testing.T) {
+	expected := `{"$schema":"https://json-schema.org/draft/2020-12/schema","properties":{"result":{"type":"string"}},"type":"object"}`
+	if got := schemaJSON(Schema("", staticEnvelope{})); got != expected {
+		fmt.Printf("Expected %s\nbut got  %s\n", expected, got)
+		t.Fail()
+	}
+}
+
+func TestSchemaUnknownTransformer(t *testing.T) {
+	expected := `{"$schema":"https://json-schema.org/draft/2020-12/schema"}`
+	for _, transformer := range []interface{}{42, ResponseTransformer(func(resp Response, httpCode int) (map[string]string, interface{}) {
+		panic("transformer called")
+	})} {
+		if got := schemaJSON(Schema(schemaUser{}, transformer)); got != expected {
+			fmt.Printf("Expected %s\nbut got  %s\n", expected, got)
+			t.Fail()
+		}
+	}
+}
+
+func TestSchemaNumberPolicyAndRedaction(t *testing.T) {
+	SetNumberPolicy(NumberPolicy{BigIntsAsStrings: true, NonFinite: NonFiniteString})
+	defer SetNumberPolicy(NumberPolicy{})
+	SetRedactedKeys("*name*", "code")
+	SetRedactionMode(RedactDrop)
+	defer SetRedactedKeys()
+	defer SetRedactionMode(RedactReplace)
+
+	type item struct {
+		ID       uint64  `json:"id"`
+		Count    int32   `json:"count"`
+		Ratio    float64 `json:"ratio"`
+		UserName string  `json:"user_name"`
+	}
+	expected := `{"$defs":{"item":{"additionalProperties":false,"properties":{` +
+		`"count":{"type":"integer"},` +
+		`"id":{"type":["integer","string"]},` +
+		`"ratio":{"anyOf":[{"type":"number"},{"enum":["NaN","Infinity","-Infinity"]}]}},` +
+		`"required":["id","count","ratio"],"type":"object"}},` +
+		`"$schema":"https://json-schema.org/draft/2020-12/schema",` +
+		`"properties":{"code":{"$ref":"#/$defs/item"}},"required":["code"],"type":"object"}`
+	if got := schemaJSON(Schema(item{}, MessageCodeTransformer("code", "status"))); got != expected {
+		fmt.Printf("Expected %s\nbut got  %s\n", expected, got)
+		t.Fail()
+	}
+}
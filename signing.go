@@ -0,0 +1,398 @@
+package jsonresponse
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DigestAlgorithm is algorithm of Content-Digest header (RFC 9530).
+type DigestAlgorithm string
+
+// Supported digest algorithms.
+const (
+	DigestSHA256 DigestAlgorithm = "sha-256"
+	DigestSHA512 DigestAlgorithm = "sha-512"
+)
+
+func (a DigestAlgorithm) hash() (hash.Hash, error) {
+	switch a {
+	case DigestSHA256:
+		return sha256.New(), nil
+	case DigestSHA512:
+		return sha512.New(), nil
+	}
+	return nil, fmt.Errorf("jsonresponse: unsupported digest algorithm %q", a)
+}
+
+// SigningKey signs and verifies signature base of HTTP message signature
+// (RFC 9421).
+type SigningKey interface {
+	// Algorithm returns name of algorithm from HTTP Signature Algorithms
+	// registry, e.g. "hmac-sha256" or "ed25519".
+	Algorithm() string
+	Sign(base []byte) ([]byte, error)
+	Verify(base, signature []byte) error
+}
+
+// KeyProvider provides keys for signing responses and for verifying them.
+type KeyProvider interface {
+	// SigningKey returns key with which responses are signed and its ID.
+	SigningKey() (keyID string, key SigningKey, err error)
+	// VerificationKey returns key with provided ID.
+	VerificationKey(keyID string) (SigningKey, error)
+}
+
+// SigningOptions configures signing of responses, see SetSigning.
+type SigningOptions struct {
+	// Digest is algorithm of Content-Digest header. Empty value disables
+	// signing.
+	Digest DigestAlgorithm
+	// Keys provide key for HTTP message signature over status code,
+	// Content-Type and Content-Digest. If nil, only Content-Digest is sent.
+	Keys KeyProvider
+	// Label of signature in Signature and Signature-Input headers, "sig"
+	// by default.
+	Label string
+}
+
+// validate checks that options can be used for signing, by signing empty
+// signature base with key of provider.
+func (o SigningOptions) validate() error {
+	if o.Digest == "" {
+		return nil
+	}
+	if _, err := o.Digest.hash(); err != nil {
+		return err
+	}
+	if o.Keys == nil {
+		return nil
+	}
+	_, key, err := o.Keys.SigningKey()
+	if err != nil {
+		return fmt.Errorf("jsonresponse: signing key: %w", err)
+	}
+	if key == nil {
+		return errors.New("jsonresponse: key provider returned no signing key")
+	}
+	if _, err := key.Sign(nil); err != nil {
+		return fmt.Errorf("jsonresponse: signing key: %w", err)
+	}
+	return nil
+}
+
+// signatureComponents are components of response covered by signature.
+var signatureComponents = []string{"@status", "content-type", "content-digest"}
+
+// ErrInvalidSignature is returned by VerifyResponse for responses whose
+// digest or signature does not match.
+var ErrInvalidSignature = errors.New("jsonresponse: invalid response signature")
+
+type hmacKey []byte
+
+// HMACKey returns key that signs with HMAC-SHA256 using shared secret.
+func HMACKey(secret []byte) SigningKey {
+	return hmacKey(secret)
+}
+
+func (k hmacKey) Algorithm() string {
+	return "hmac-sha256"
+}
+
+func (k hmacKey) Sign(base []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, k)
+	mac.Write(base)
+	return mac.Sum(nil), nil
+}
+
+func (k hmacKey) Verify(base, signature []byte) error {
+	expected, _ := k.Sign(base)
+	if !hmac.Equal(expected, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+type ed25519Key struct {
+	private ed25519.PrivateKey
+	public  ed25519.PublicKey
+}
+
+// Ed25519Key returns key that signs with Ed25519 private key.
+func Ed25519Key(private ed25519.PrivateKey) SigningKey {
+	return ed25519Key{private: private, public: private.Public().(ed25519.PublicKey)}
+}
+
+// Ed25519PublicKey returns key that can only verify Ed25519 signatures, e.g.
+// for clients that verify responses.
+func Ed25519PublicKey(public ed25519.PublicKey) SigningKey {
+	return ed25519Key{public: public}
+}
+
+func (k ed25519Key) Algorithm() string {
+	return "ed25519"
+}
+
+func (k ed25519Key) Sign(base []byte) ([]byte, error) {
+	if k.private == nil {
+		return nil, errors.New("jsonresponse: ed25519 key without private key can not sign")
+	}
+	return ed25519.Sign(k.private, base), nil
+}
+
+func (k ed25519Key) Verify(base, signature []byte) error {
+	if !ed25519.Verify(k.public, base, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+type staticKey struct {
+	id  string
+	key SigningKey
+}
+
+// StaticKey returns provider of single key with ID.
+func StaticKey(keyID string, key SigningKey) KeyProvider {
+	return staticKey{id: keyID, key: key}
+}
+
+func (s staticKey) SigningKey() (string, SigningKey, error) {
+	return s.id, s.key, nil
+}
+
+func (s staticKey) VerificationKey(keyID string) (SigningKey, error) {
+	if keyID != s.id {
+		return nil, fmt.Errorf("jsonresponse: unknown key %q", keyID)
+	}
+	return s.key, nil
+}
+
+// signBody sets Content-Digest header of encoded body, and signature headers
+// if keys are configured (see SetSigning).
+func signBody(header http.Header, httpCode int, body []byte, o SigningOptions) error {
+	if o.Digest == "" {
+		return nil
+	}
+	digest, err := contentDigest(o.Digest, body)
+	if err != nil {
+		return err
+	}
+	header.Set("Content-Digest", digest)
+	if o.Keys == nil {
+		return nil
+	}
+
+	keyID, key, err := o.Keys.SigningKey()
+	if err != nil {
+		return err
+	}
+	params := signatureParams(signatureComponents, time.Now().Unix(), keyID, key.Algorithm())
+	base := signatureBase(signatureComponents, params, httpCode, header)
+	signature, err := key.Sign([]byte(base))
+	if err != nil {
+		return err
+	}
+	label := o.Label
+	if label == "" {
+		label = "sig"
+	}
+	header.Set("Signature-Input", label+"="+params)
+	header.Set("Signature", label+"=:"+base64.StdEncoding.EncodeToString(signature)+":")
+	return nil
+}
+
+// contentDigest returns value of Content-Digest header for body.
+func contentDigest(algorithm DigestAlgorithm, body []byte) (string, error) {
+	h, err := algorithm.hash()
+	if err != nil {
+		return "", err
+	}
+	h.Write(body)
+	return string(algorithm) + "=:" + base64.StdEncoding.EncodeToString(h.Sum(nil)) + ":", nil
+}
+
+// signatureParams returns serialized signature parameters, which are value
+// of Signature-Input header without label.
+func signatureParams(components []string, created int64, keyID, algorithm string) string {
+	quoted := make([]string, len(components))
+	for i, c := range components {
+		quoted[i] = strconv.Quote(c)
+	}
+	return fmt.Sprintf("(%s);created=%d;keyid=%s;alg=%s",
+		strings.Join(quoted, " "), created, strconv.Quote(keyID), strconv.Quote(algorithm))
+}
+
+// signatureBase returns signature base (RFC 9421, section 2.5) of response.
+func signatureBase(components []string, params string, httpCode int, header http.Header) string {
+	var b strings.Builder
+	for _, c := range components {
+		value := strconv.Itoa(httpCode)
+		if c != "@status" {
+			value = strings.TrimSpace(strings.Join(header.Values(c), ", "))
+		}
+		fmt.Fprintf(&b, "%q: %s\n", c, value)
+	}
+	fmt.Fprintf(&b, "%q: %s", "@signature-params", params)
+	return b.String()
+}
+
+// VerifyOption configures VerifyResponse.
+type VerifyOption func(o *verifyOptions)
+
+type verifyOptions struct {
+	maxAge    time.Duration
+	clockSkew time.Duration
+}
+
+// MaxAge rejects signatures created more than maxAge ago, or later than now.
+// Clocks of signer and verifier can differ by up to skew. Without it, age of
+// signature is not checked, so signed response can be replayed.
+func MaxAge(maxAge, skew time.Duration) VerifyOption {
+	return func(o *verifyOptions) {
+		o.maxAge = maxAge
+		o.clockSkew = skew
+	}
+}
+
+// VerifyResponse verifies Content-Digest header of response against its body,
+// which has to be already read. If keys are provided, HTTP message signature
+// of response has to be valid, cover status code, Content-Type and
+// Content-Digest, and its algorithm has to match algorithm of key. Time when
+// signature was created is checked only with MaxAge option.
+func VerifyResponse(resp *http.Response, body []byte, keys KeyProvider, opts ...VerifyOption) error {
+	var o verifyOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	digest := resp.Header.Get("Content-Digest")
+	algorithm := DigestAlgorithm(strings.SplitN(digest, "=", 2)[0])
+	expected, err := contentDigest(algorithm, body)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if digest != expected {
+		return fmt.Errorf("%w: content digest does not match body", ErrInvalidSignature)
+	}
+	if keys == nil {
+		return nil
+	}
+
+	label, params, ok := strings.Cut(resp.Header.Get("Signature-Input"), "=")
+	if !ok {
+		return fmt.Errorf("%w: missing Signature-Input header", ErrInvalidSignature)
+	}
+	p, err := parseSignatureParams(params)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	for _, required := range signatureComponents {
+		if !contains(p.components, required) {
+			return fmt.Errorf("%w: signature does not cover %s", ErrInvalidSignature, required)
+		}
+	}
+	if o.maxAge > 0 {
+		if p.created == nil {
+			return fmt.Errorf("%w: signature has no creation time", ErrInvalidSignature)
+		}
+		now := time.Now()
+		created := time.Unix(*p.created, 0)
+		if created.After(now.Add(o.clockSkew)) || created.Before(now.Add(-o.maxAge-o.clockSkew)) {
+			return fmt.Errorf("%w: signature created at %s is expired", ErrInvalidSignature, created.UTC().Format(time.RFC3339))
+		}
+	}
+	signature, err := signatureValue(resp.Header.Get("Signature"), label)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	key, err := keys.VerificationKey(p.keyID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if p.algorithm != "" && p.algorithm != key.Algorithm() {
+		return fmt.Errorf("%w: signature algorithm %s does not match key", ErrInvalidSignature, p.algorithm)
+	}
+	base := signatureBase(p.components, params, resp.StatusCode, resp.Header)
+	if err := key.Verify([]byte(base), signature); err != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// signatureParameters are parsed parameters of signature.
+type signatureParameters struct {
+	components []string
+	keyID      string
+	algorithm  string
+	// created is nil if signature has no creation time
+	created *int64
+}
+
+// parseSignatureParams returns covered components and parameters of
+// signature, e.g. `("@status" "content-type");created=1;keyid="k"`.
+func parseSignatureParams(params string) (p signatureParameters, err error) {
+	if !strings.HasPrefix(params, "(") {
+		return p, errors.New("invalid signature parameters")
+	}
+	end := strings.IndexByte(params, ')')
+	if end < 0 {
+		return p, errors.New("invalid signature parameters")
+	}
+	for _, item := range strings.Fields(params[1:end]) {
+		c, err := strconv.Unquote(item)
+		if err != nil {
+			return p, errors.New("invalid covered component " + item)
+		}
+		p.components = append(p.components, c)
+	}
+	for _, param := range strings.Split(params[end+1:], ";")[1:] {
+		name, value, _ := strings.Cut(param, "=")
+		switch name {
+		case "keyid":
+			if p.keyID, err = strconv.Unquote(value); err != nil {
+				return p, errors.New("invalid key ID " + value)
+			}
+		case "alg":
+			if p.algorithm, err = strconv.Unquote(value); err != nil {
+				return p, errors.New("invalid algorithm " + value)
+			}
+		case "created":
+			created, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return p, errors.New("invalid creation time " + value)
+			}
+			p.created = &created
+		}
+	}
+	return p, nil
+}
+
+// signatureValue returns signature with label from Signature header.
+func signatureValue(header, label string) ([]byte, error) {
+	for _, member := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(member), "=")
+		if name == label && len(value) >= 2 && value[0] == ':' && value[len(value)-1] == ':' {
+			return base64.StdEncoding.DecodeString(value[1 : len(value)-1])
+		}
+	}
+	return nil, errors.New("missing signature " + label)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
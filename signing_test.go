@@ -0,0 +1,223 @@
+package jsonresponse
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContentDigest(t *testing.T) {
+	SetIndent(false)
+	SetSigning(SigningOptions{Digest: DigestSHA256})
+	defer SetSigning(SigningOptions{})
+
+	recorder := httptest.NewRecorder()
+	New("value").OK(recorder)
+	// digest of {"data":"value"}\n
+	expected := "sha-256=:kFKEzZRne0+vex7sBAP6pK0Mkn6tZnhrcWKpe17u5ig=:"
+	if got := recorder.Header().Get("Content-Digest"); got != expected {
+		fmt.Printf("Expected Content-Digest %s, got %s\n", expected, got)
+		t.Fail()
+	}
+	if err := VerifyResponse(recorder.Result(), recorder.Body.Bytes(), nil); err != nil {
+		fmt.Printf("Expected valid digest, got %v\n", err)
+		t.Fail()
+	}
+	if err := VerifyResponse(recorder.Result(), []byte(`{"data":"other"}`), nil); !errors.Is(err, ErrInvalidSignature) {
+		fmt.Printf("Expected invalid digest, got %v\n", err)
+		t.Fail()
+	}
+}
+
+func TestSignature(t *testing.T) {
+	_, private, _ := ed25519.GenerateKey(nil)
+	for _, c := range []struct {
+		signing KeyProvider
+		verify  KeyProvider
+	}{
+		{StaticKey("shared", HMACKey([]byte("secret"))), StaticKey("shared", HMACKey([]byte("secret")))},
+		{StaticKey("ed", Ed25519Key(private)), StaticKey("ed", Ed25519PublicKey(private.Public().(ed25519.PublicKey)))},
+	} {
+		SetSigning(SigningOptions{Digest: DigestSHA512, Keys: c.signing})
+		recorder := httptest.NewRecorder()
+		New(map[string]int{"id": 1}).Created(recorder)
+		keyID, key, _ := c.signing.SigningKey()
+
+		input := `^sig=\("@status" "content-type" "content-digest"\);created=[0-9]+;keyid="` + keyID + `";alg="` + key.Algorithm() + `"$`
+		if got := recorder.Header().Get("Signature-Input"); !regexp.MustCompile(input).MatchString(got) {
+			fmt.Printf("Unexpected Signature-Input %s\n", got)
+			t.Fail()
+		}
+		if err := VerifyResponse(recorder.Result(), recorder.Body.Bytes(), c.verify); err != nil {
+			fmt.Printf("Expected valid signature with %s, got %v\n", key.Algorithm(), err)
+			t.Fail()
+		}
+
+		tampered := recorder.Result()
+		tampered.StatusCode = 200
+		if err := VerifyResponse(tampered, recorder.Body.Bytes(), c.verify); !errors.Is(err, ErrInvalidSignature) {
+			fmt.Printf("Expected invalid signature of tampered response with %s, got %v\n", key.Algorithm(), err)
+			t.Fail()
+		}
+		verifyKey, _ := c.verify.VerificationKey(keyID)
+		if err := VerifyResponse(recorder.Result(), recorder.Body.Bytes(), StaticKey("other", verifyKey)); !errors.Is(err, ErrInvalidSignature) {
+			fmt.Printf("Expected unknown key to fail with %s, got %v\n", key.Algorithm(), err)
+			t.Fail()
+		}
+	}
+	SetSigning(SigningOptions{})
+}
+
+func TestDecodeWithVerification(t *testing.T) {
+	keys := StaticKey("shared", HMACKey([]byte("secret")))
+	SetSigning(SigningOptions{Digest: DigestSHA256, Keys: keys})
+	defer SetSigning(SigningOptions{})
+
+	recorder := httptest.NewRecorder()
+	New(42).OK(recorder)
+	if data, err := DecodeResponse[int](recorder.Result(), WithVerification(keys)); err != nil || data != 42 {
+		fmt.Printf("Expected verified data, got %v (%v)\n", data, err)
+		t.Fail()
+	}
+
+	resp := recorder.Result()
+	resp.Body = io.NopCloser(bytes.NewReader([]byte(`{"data":43}` + "\n")))
+	if _, err := DecodeResponse[int](resp, WithVerification(keys)); !errors.Is(err, ErrInvalidSignature) {
+		fmt.Printf("Expected tampered body to fail verification, got %v\n", err)
+		t.Fail()
+	}
+}
+
+// failingKeys is KeyProvider that can not provide signing key once it is
+// unavailable.
+type failingKeys struct {
+	unavailable bool
+}
+
+func (k *failingKeys) SigningKey() (string, SigningKey, error) {
+	if k.unavailable {
+		return "", nil, errors.New("key store unavailable")
+	}
+	return "shared", HMACKey([]byte("secret")), nil
+}
+
+func (k *failingKeys) VerificationKey(keyID string) (SigningKey, error) {
+	return nil, errors.New("key store unavailable")
+}
+
+// failingJWSKey is JWSKey that can not sign.
+type failingJWSKey struct{}
+
+func (failingJWSKey) Algorithm() string { return "HS256" }
+func (failingJWSKey) Sign(input []byte) ([]byte, error) {
+	return nil, errors.New("key store unavailable")
+}
+func (failingJWSKey) Verify(input, signature []byte) error { return nil }
+
+func TestSigningFailure(t *testing.T) {
+	SetIndent(false)
+	hooks := &recordingHooks{}
+	SetHooks(hooks)
+	defer SetHooks()
+
+	keys := &failingKeys{}
+	for _, c := range []struct {
+		signing  SigningOptions
+		response Response
+	}{
+		{SigningOptions{Digest: DigestSHA256, Keys: keys}, New("value")},
+		{SigningOptions{}, New("value").JWS(JWSOptions{Key: failingJWSKey{}})},
+	} {
+		keys.unavailable = false
+		if err := SetSigning(c.signing); err != nil {
+			fmt.Printf("Expected valid signing options, got %v\n", err)
+			t.Fail()
+		}
+		keys.unavailable = true
+		hooks.calls = nil
+		recorder := httptest.NewRecorder()
+		c.response.Header("Cache-Control", "max-age=60").OK(recorder)
+
+		expected := `{"code":500,"message":"Internal Server Error"}` + "\n"
+		if recorder.Code != 500 || recorder.Body.String() != expected || recorder.Header().Get("Cache-Control") != "" ||
+			recorder.Header().Get("Content-Digest") != "" || recorder.Header().Get("Content-Type") == JWSContentType {
+			fmt.Printf("Expected unsigned error, got %d %v %s\n", recorder.Code, recorder.Header(), recorder.Body.String())
+			t.Fail()
+		}
+		if len(hooks.calls) != 2 || !strings.HasPrefix(hooks.calls[1], "after 500 <nil> 47 jsonresponse: signing response: key store unavailable") {
+			fmt.Printf("Expected signing failure reported to hooks, got %v\n", hooks.calls)
+			t.Fail()
+		}
+	}
+	SetSigning(SigningOptions{})
+}
+
+func TestSetSigningValidatesOptions(t *testing.T) {
+	_, private, _ := ed25519.GenerateKey(nil)
+	valid := SigningOptions{Digest: DigestSHA256, Keys: StaticKey("ed", Ed25519Key(private))}
+	if err := SetSigning(valid); err != nil {
+		fmt.Printf("Expected valid signing options, got %v\n", err)
+		t.Fail()
+	}
+	defer SetSigning(SigningOptions{})
+
+	for _, o := range []SigningOptions{
+		{Digest: "md5"},
+		{Digest: DigestSHA256, Keys: StaticKey("ed", Ed25519PublicKey(private.Public().(ed25519.PublicKey)))},
+		{Digest: DigestSHA256, Keys: &failingKeys{unavailable: true}},
+	} {
+		if err := SetSigning(o); err == nil {
+			fmt.Printf("Expected error for signing options %v\n", o)
+			t.Fail()
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	New(42).OK(recorder)
+	if !strings.Contains(recorder.Header().Get("Signature-Input"), `alg="ed25519"`) {
+		fmt.Printf("Expected previous signing options to be kept, got %v\n", recorder.Header())
+		t.Fail()
+	}
+}
+
+func TestVerifyCreatedAndAlgorithm(t *testing.T) {
+	keys := StaticKey("shared", HMACKey([]byte("secret")))
+	SetSigning(SigningOptions{Digest: DigestSHA256, Keys: keys})
+	defer SetSigning(SigningOptions{})
+
+	recorder := httptest.NewRecorder()
+	New(42).OK(recorder)
+	if err := VerifyResponse(recorder.Result(), recorder.Body.Bytes(), keys, MaxAge(time.Minute, time.Second)); err != nil {
+		fmt.Printf("Expected fresh signature, got %v\n", err)
+		t.Fail()
+	}
+	if _, err := DecodeResponse[int](recorder.Result(), WithVerification(keys, MaxAge(time.Minute, 0))); err != nil {
+		fmt.Printf("Expected fresh signature when decoding, got %v\n", err)
+		t.Fail()
+	}
+
+	for _, created := range []time.Time{time.Now().Add(-time.Hour), time.Now().Add(time.Hour)} {
+		resp := recorder.Result()
+		resp.Header.Set("Signature-Input", regexp.MustCompile(`created=[0-9]+`).
+			ReplaceAllString(resp.Header.Get("Signature-Input"), fmt.Sprintf("created=%d", created.Unix())))
+		if err := VerifyResponse(resp, recorder.Body.Bytes(), keys, MaxAge(time.Minute, time.Second)); !errors.Is(err, ErrInvalidSignature) || !strings.Contains(err.Error(), "expired") {
+			fmt.Printf("Expected signature created at %v to be rejected, got %v\n", created, err)
+			t.Fail()
+		}
+	}
+
+	_, private, _ := ed25519.GenerateKey(nil)
+	other := StaticKey("shared", Ed25519PublicKey(private.Public().(ed25519.PublicKey)))
+	if err := VerifyResponse(recorder.Result(), recorder.Body.Bytes(), other); !errors.Is(err, ErrInvalidSignature) ||
+		!strings.Contains(err.Error(), "algorithm hmac-sha256 does not match key") {
+		fmt.Printf("Expected algorithm mismatch, got %v\n", err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,47 @@
+// Package sloghooks provides jsonresponse hooks that log responses with
+// log/slog.
+//
+// Example:
+//
+//	jsonresponse.SetHooks(sloghooks.New(slog.Default()))
+package sloghooks
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Hooks logs every written response at info level, or at error level if
+// it could not be encoded. Start of encoding is logged at debug level.
+type Hooks struct {
+	logger *slog.Logger
+}
+
+// New creates hooks that log to provided logger.
+func New(logger *slog.Logger) *Hooks {
+	return &Hooks{logger: logger}
+}
+
+// BeforeEncode logs type of data that is about to be encoded.
+func (h *Hooks) BeforeEncode(ctx context.Context, status int, data interface{}) {
+	h.logger.LogAttrs(ctx, slog.LevelDebug, "encoding response",
+		slog.Int("status", status),
+		slog.String("type", fmt.Sprintf("%T", data)),
+	)
+}
+
+// AfterWrite logs written response.
+func (h *Hooks) AfterWrite(ctx context.Context, status int, bytes int, duration time.Duration, err error) {
+	attrs := []slog.Attr{
+		slog.Int("status", status),
+		slog.Int("bytes", bytes),
+		slog.Duration("duration", duration),
+	}
+	if err != nil {
+		h.logger.LogAttrs(ctx, slog.LevelError, "response encoding failed", append(attrs, slog.String("error", err.Error()))...)
+		return
+	}
+	h.logger.LogAttrs(ctx, slog.LevelInfo, "response written", attrs...)
+}
@@ -0,0 +1,65 @@
+package sloghooks
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/delicb/jsonresponse"
+)
+
+// newLogger returns logger that writes to buffer without time and duration,
+// so output can be compared.
+func newLogger(buf *bytes.Buffer, level slog.Level) *slog.Logger {
+	return slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == "duration" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+func TestHooks(t *testing.T) {
+	buf := &bytes.Buffer{}
+	hooks := New(newLogger(buf, slog.LevelDebug))
+	jsonresponse.SetHooks(hooks)
+	defer jsonresponse.SetHooks()
+
+	jsonresponse.New([]int{1, 2}).OK(httptest.NewRecorder())
+	expected := "level=DEBUG msg=\"encoding response\" status=200 type=[]int\n" +
+		"level=INFO msg=\"response written\" status=200 bytes=15\n"
+	if buf.String() != expected {
+		fmt.Printf("Expected\n%sbut got\n%s", expected, buf.String())
+		t.Fail()
+	}
+}
+
+func TestHooksLevel(t *testing.T) {
+	buf := &bytes.Buffer{}
+	hooks := New(newLogger(buf, slog.LevelInfo))
+
+	hooks.BeforeEncode(httptest.NewRequest("GET", "/", nil).Context(), http.StatusOK, nil)
+	if buf.Len() != 0 {
+		fmt.Printf("Expected encoding not logged at info level, got %s", buf.String())
+		t.Fail()
+	}
+
+	hooks.AfterWrite(httptest.NewRequest("GET", "/", nil).Context(), http.StatusInternalServerError, 0, 0, errors.New("broken"))
+	expected := "level=ERROR msg=\"response encoding failed\" status=500 bytes=0 error=broken\n"
+	if buf.String() != expected {
+		fmt.Printf("Expected %sbut got %s", expected, buf.String())
+		t.Fail()
+	}
+	if strings.Contains(buf.String(), "response written") {
+		fmt.Printf("Expected failed response not logged as written\n")
+		t.Fail()
+	}
+}
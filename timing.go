@@ -0,0 +1,154 @@
+package jsonresponse
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timing is server timing metric, sent to client in Server-Timing header.
+type Timing struct {
+	Name        string
+	Duration    time.Duration
+	Description string
+}
+
+// Timing adds server timing metric to response. Metrics are sent in
+// Server-Timing header, and in "meta" object of envelope if it is enabled
+// via SetTimingsInMeta. Metrics whose name is not valid token (RFC 9110)
+// are not sent in header.
+func (r Response) Timing(name string, duration time.Duration, description string) Response {
+	r.timings = append(r.timings[:len(r.timings):len(r.timings)], Timing{name, duration, description})
+	return r
+}
+
+type timingsKey struct{}
+
+// timings collects metrics during request.
+type timings struct {
+	lock    sync.Mutex
+	metrics []Timing
+}
+
+// WithTimings returns context in which timers can be started via StartTimer.
+// Metrics are sent with response that is sent for request with that context
+// (e.g. via OKFor).
+//
+// Example:
+//
+//	req = req.WithContext(jsonresponse.WithTimings(req.Context()))
+func WithTimings(ctx context.Context) context.Context {
+	return context.WithValue(ctx, timingsKey{}, &timings{})
+}
+
+// StartTimer starts timer with provided name and returns function that stops
+// it and records its duration. If context was not created via WithTimings,
+// duration is not recorded.
+//
+// Example:
+//
+//	stop := jsonresponse.StartTimer(req.Context(), "db")
+//	rows, err := db.Query(...)
+//	stop()
+func StartTimer(ctx context.Context, name string) (stop func()) {
+	t, ok := ctx.Value(timingsKey{}).(*timings)
+	if !ok {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		t.lock.Lock()
+		defer t.lock.Unlock()
+		t.metrics = append(t.metrics, Timing{Name: name, Duration: time.Since(start)})
+	}
+}
+
+// timingsFor returns metrics of response followed by metrics collected in
+// context of request.
+func (r Response) timingsFor(req *http.Request) []Timing {
+	metrics := r.timings
+	if req == nil {
+		return metrics
+	}
+	if t, ok := req.Context().Value(timingsKey{}).(*timings); ok {
+		t.lock.Lock()
+		defer t.lock.Unlock()
+		metrics = append(metrics[:len(metrics):len(metrics)], t.metrics...)
+	}
+	return metrics
+}
+
+// serverTiming returns value of Server-Timing header with provided metrics.
+// Metrics with invalid name are skipped.
+func serverTiming(metrics []Timing) string {
+	parts := make([]string, 0, len(metrics))
+	for _, m := range metrics {
+		if !isToken(m.Name) {
+			continue
+		}
+		part := m.Name + ";dur=" + milliseconds(m.Duration)
+		if m.Description != "" {
+			part += ";desc=" + quotedString(m.Description)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// isToken returns true if s is token as defined by RFC 9110.
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 0x80 || c <= ' ' || c == 0x7f || strings.IndexByte(`"(),/:;<=>?@[\]{}`, c) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// quotedString returns s as quoted-string defined by RFC 9110. Only quote and
+// backslash are escaped, control characters, which can not be sent in
+// header, are removed.
+func quotedString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < ' ' && c != '\t' || c == 0x7f:
+			// removed
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// timingsMeta returns metrics as they are included in "meta" object.
+func timingsMeta(metrics []Timing) []interface{} {
+	result := make([]interface{}, len(metrics))
+	for i, m := range metrics {
+		metric := map[string]interface{}{"name": m.Name, "dur": json.Number(milliseconds(m.Duration))}
+		if m.Description != "" {
+			metric["desc"] = m.Description
+		}
+		result[i] = metric
+	}
+	return result
+}
+
+// milliseconds formats duration in milliseconds with microsecond precision.
+func milliseconds(d time.Duration) string {
+	return strconv.FormatFloat(float64(d/time.Microsecond)/1000, 'f', -1, 64)
+}
@@ -0,0 +1,78 @@
+package jsonresponse
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestTimingHeader(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	New("value").
+		Timing("db", 12500*time.Microsecond, "Database").
+		Timing("cache", 2*time.Millisecond, "").
+		OK(recorder)
+	expected := `db;dur=12.5;desc="Database", cache;dur=2`
+	if got := recorder.Header().Get("Server-Timing"); got != expected {
+		fmt.Printf("Expected Server-Timing %s, got %s\n", expected, got)
+		t.Fail()
+	}
+}
+
+func TestTimingHeaderEscaping(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	New("value").
+		Timing("db", time.Millisecond, `say "hi" \ café`).
+		Timing("bad name", time.Millisecond, "").
+		Timing("cache", time.Millisecond, "line\nbreak\ttab").
+		OK(recorder)
+	expected := `db;dur=1;desc="say \"hi\" \\ café", cache;dur=1;desc="linebreak` + "\t" + `tab"`
+	if got := recorder.Header().Get("Server-Timing"); got != expected {
+		fmt.Printf("Expected Server-Timing %s, got %s\n", expected, got)
+		t.Fail()
+	}
+
+	recorder = httptest.NewRecorder()
+	New("value").Timing("a/b", time.Millisecond, "").OK(recorder)
+	if _, ok := recorder.Header()["Server-Timing"]; ok {
+		fmt.Printf("Expected no Server-Timing header, got %v\n", recorder.Header())
+		t.Fail()
+	}
+}
+
+func TestTimingFromContext(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req = req.WithContext(WithTimings(req.Context()))
+	stop := StartTimer(req.Context(), "db")
+	time.Sleep(time.Millisecond)
+	stop()
+
+	recorder := httptest.NewRecorder()
+	New("value").Timing("render", time.Millisecond, "").OKFor(recorder, req)
+	if got := recorder.Header().Get("Server-Timing"); !regexp.MustCompile(`^render;dur=1, db;dur=[0-9.]+$`).MatchString(got) {
+		fmt.Printf("Unexpected Server-Timing %s\n", got)
+		t.Fail()
+	}
+
+	// timer in context without collector is ignored
+	StartTimer(httptest.NewRequest("GET", "/", nil).Context(), "db")()
+}
+
+func TestTimingsInMeta(t *testing.T) {
+	SetTimingsInMeta(true)
+	defer SetTimingsInMeta(false)
+
+	recorder := httptest.NewRecorder()
+	Page([]int{1}, PageInfo{Limit: 1, Total: 1}).Timing("db", 1500*time.Microsecond, "Database").OK(recorder)
+	var body map[string]interface{}
+	json.Unmarshal(recorder.Body.Bytes(), &body)
+	meta, _ := json.Marshal(body["meta"])
+	expected := `{"limit":1,"offset":0,"timings":[{"desc":"Database","dur":1.5,"name":"db"}],"total":1}`
+	if string(meta) != expected {
+		fmt.Printf("Expected meta %s, got %s\n", expected, meta)
+		t.Fail()
+	}
+}
@@ -1,32 +1,106 @@
 package jsonresponse
 
+import "net/http"
+
 // ResponseTransformer is function that transforms response before it is send
 // to client. Default implementation is provided, but it can suite
 // more specific needs.
 type ResponseTransformer func(resp Response, httpCode int) (headers map[string]string, result interface{})
 
+// RequestTransformer is same as ResponseTransformer, except that it also
+// receives request for which response is sent. This allows including request
+// specific values (like request ID, path or locale) in response.
+// Request is nil when response is sent without it (e.g. via OK instead of OKFor),
+// so request transformers have to handle that case.
+type RequestTransformer func(req *http.Request, resp Response, httpCode int) (headers map[string]string, result interface{})
+
+// AdaptTransformer converts ResponseTransformer to RequestTransformer that
+// ignores request.
+func AdaptTransformer(t ResponseTransformer) RequestTransformer {
+	if t == nil {
+		return nil
+	}
+	return RequestTransformer(func(req *http.Request, resp Response, httpCode int) (headers map[string]string, result interface{}) {
+		return t(resp, httpCode)
+	})
+}
+
+// StatusClass is class of HTTP status codes, defined by first digit of code.
+type StatusClass int
+
+// Status classes defined by RFC 7231.
+const (
+	StatusClassInformational StatusClass = 1
+	StatusClassSuccess       StatusClass = 2
+	StatusClassRedirection   StatusClass = 3
+	StatusClassClientError   StatusClass = 4
+	StatusClassServerError   StatusClass = 5
+)
+
+// ClassOf returns status class of provided HTTP status code.
+func ClassOf(httpCode int) StatusClass {
+	return StatusClass(httpCode / 100)
+}
+
+// TransformerProvider can be implemented by data that has to be sent with
+// its own transformer, e.g. document that is sent without envelope. It has
+// precedence over all transformers set via SetTransformer and similar.
+type TransformerProvider interface {
+	Transformer() RequestTransformer
+}
+
+// DataPathDescriber can be implemented by transformers to declare path of
+// keys under which they place data of response in their result, e.g. ["data"]
+// for default transformer. Empty path means that data is not wrapped in
+// envelope.
+type DataPathDescriber interface {
+	DataPath() []string
+}
+
+// Envelope is transformer that declares where it places data of response,
+// so it can be found in its result without calling it (see DataPath).
+// It can be set via SetEnvelope, SetEnvelopeFor and SetEnvelopeForCode.
+type Envelope struct {
+	// Transformer wraps data of response in envelope.
+	Transformer RequestTransformer
+	// Path of keys under which Transformer places data, e.g. ["data"].
+	// Empty path means that data is not wrapped.
+	Path []string
+}
+
+// DataPath returns path of keys under which transformer places data.
+func (e Envelope) DataPath() []string {
+	return append([]string{}, e.Path...)
+}
+
 // PassthroughTransformer only returns data as they are in response without modification.
 func PassthroughTransformer(resp Response, httpCode int) (headers map[string]string, result interface{}) {
 	return make(map[string]string), resp.Data
 }
 
 // MessageCodeTransformer wraps response into map with data and code fields.
-// Data and code fields can be defined as function parameters.
-func MessageCodeTransformer(dataField string, codeField string) ResponseTransformer {
-	return ResponseTransformer(func(resp Response, httpCode int) (headers map[string]string, result interface{}) {
+// Data and code fields can be defined as function parameters. Metadata of
+// response is included if it is not empty. It is set via SetEnvelope, so path
+// of data is known.
+func MessageCodeTransformer(dataField string, codeField string) Envelope {
+	transformer := ResponseTransformer(func(resp Response, httpCode int) (headers map[string]string, result interface{}) {
 		h := map[string]string{}
 		r := map[string]interface{}{
 			dataField: resp.Data,
 			codeField: httpCode,
 		}
+		if len(resp.Metadata) > 0 {
+			r[resp.metaField()] = resp.Metadata
+		}
 		return h, r
 	})
+	return Envelope{Transformer: AdaptTransformer(transformer), Path: []string{dataField}}
 }
 
 // MessageCodeExcuseTransformer is same as MessageCodeTransformer, except that
 // it adds "programming-excuse" field with Excuse field from response.
-func MessageCodeExcuseTransformer(dataField string, codeField string) ResponseTransformer {
-	return ResponseTransformer(func(resp Response, httpCode int) (headers map[string]string, result interface{}) {
+func MessageCodeExcuseTransformer(dataField string, codeField string) Envelope {
+	transformer := ResponseTransformer(func(resp Response, httpCode int) (headers map[string]string, result interface{}) {
 		h := map[string]string{}
 		r := map[string]interface{}{
 			dataField: resp.Data,
@@ -35,10 +109,17 @@ func MessageCodeExcuseTransformer(dataField string, codeField string) ResponseTr
 		if resp.Excuse != "" {
 			r["programming-excuse"] = resp.Excuse
 		}
+		if len(resp.Metadata) > 0 {
+			r[resp.metaField()] = resp.Metadata
+		}
 		return h, r
 	})
+	return Envelope{Transformer: AdaptTransformer(transformer), Path: []string{dataField}}
 }
 
+// defaultEnvelope is default transformer with path of data it produces.
+var defaultEnvelope = Envelope{Transformer: AdaptTransformer(defaultTransformer), Path: []string{"data"}}
+
 // defaultTransformer just wraps response dict with key data.
 func defaultTransformer(resp Response, httpCode int) (headers map[string]string, result interface{}) {
 	h := map[string]string{}
@@ -48,5 +129,8 @@ func defaultTransformer(resp Response, httpCode int) (headers map[string]string,
 	if resp.Excuse != "" {
 		r["programming-excuse"] = resp.Excuse
 	}
+	if len(resp.Metadata) > 0 {
+		r[resp.metaField()] = resp.Metadata
+	}
 	return h, r
 }
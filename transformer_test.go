@@ -32,13 +32,17 @@ func TestMessageCodeTransformer(t *testing.T) {
 		"data": "code",
 	} {
 		transformer := MessageCodeTransformer(dataField, codeField)
+		if path := transformer.DataPath(); !reflect.DeepEqual(path, []string{dataField}) {
+			fmt.Printf("Expected data path [%s], got %v\n", dataField, path)
+			t.Fail()
+		}
 
 		for _, r := range []Response{
 			Empty(),
 			New("foo"),
 			New(map[string]string{"foo": "bar"}),
 		} {
-			headers, res := transformer(r, status)
+			headers, res := transformer.Transformer(nil, r, status)
 			result := res.(map[string]interface{})
 			if len(headers) != 0 {
 				fmt.Println("MessageCodeTransformer should return not headers.")
@@ -73,13 +77,17 @@ func TestMessageCodeExcuseTransformer(t *testing.T) {
 		"data": "code",
 	} {
 		transformer := MessageCodeExcuseTransformer(dataField, codeField)
+		if path := transformer.DataPath(); !reflect.DeepEqual(path, []string{dataField}) {
+			fmt.Printf("Expected data path [%s], got %v\n", dataField, path)
+			t.Fail()
+		}
 
 		for _, r := range []Response{
 			Empty().WithProgrammingExcuse(),
 			New("foo").WithProgrammingExcuse(),
 			New(map[string]string{"foo": "bar"}).WithProgrammingExcuse(),
 		} {
-			headers, res := transformer(r, status)
+			headers, res := transformer.Transformer(nil, r, status)
 			result := res.(map[string]interface{})
 			if len(headers) != 0 {
 				fmt.Println("MessageCodeTransformer should return not headers.")
@@ -119,3 +127,74 @@ func TestMessageCodeExcuseTransformer(t *testing.T) {
 		}
 	}
 }
+
+func TestChainTransformers(t *testing.T) {
+	first := func(r Response, httpCode int) (headers map[string]string, result interface{}) {
+		return map[string]string{"X-First": "1", "X-Both": "first"}, map[string]interface{}{"inner": r.Data}
+	}
+	second := func(r Response, httpCode int) (headers map[string]string, result interface{}) {
+		return map[string]string{"X-Both": "second"}, map[string]interface{}{"outer": r.Data}
+	}
+	headers, result := ChainTransformers(first, second)(New("foo"), http.StatusOK)
+	expected := map[string]interface{}{
+		"outer": map[string]interface{}{"inner": "foo"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		fmt.Printf("Expected %#v\nbut got  %#v\n", expected, result)
+		t.Fail()
+	}
+	if headers["X-First"] != "1" {
+		fmt.Println("Headers from first transformer not merged.")
+		t.Fail()
+	}
+	if headers["X-Both"] != "second" {
+		fmt.Println("Headers from later transformer should take precedence.")
+		t.Fail()
+	}
+}
+
+func TestWrapTransformer(t *testing.T) {
+	transformer := WrapTransformer(defaultTransformer,
+		AddHeader("X-Outer", "outer"),
+		AddMeta("version", "v1"),
+		RenameField("data", "payload"),
+		AddHeader("X-Outer", "inner"),
+	)
+	headers, res := transformer(New("foo"), http.StatusOK)
+	result := res.(map[string]interface{})
+	if headers["X-Outer"] != "outer" {
+		fmt.Println("Header from outer middleware should take precedence.")
+		t.Fail()
+	}
+	if _, ok := result["data"]; ok {
+		fmt.Println("Field data should be renamed.")
+		t.Fail()
+	}
+	if result["payload"] != "foo" {
+		fmt.Println("Renamed field does not contain data.")
+		t.Fail()
+	}
+	expectedMeta := map[string]interface{}{"version": "v1"}
+	if !reflect.DeepEqual(result["meta"], expectedMeta) {
+		fmt.Printf("Expected meta %#v\nbut got  %#v\n", expectedMeta, result["meta"])
+		t.Fail()
+	}
+}
+
+func TestMiddlewareIgnoresNonMapResults(t *testing.T) {
+	transformer := WrapTransformer(PassthroughTransformer, AddMeta("version", "v1"), RenameField("a", "b"))
+	_, result := transformer(New("foo"), http.StatusOK)
+	if result != "foo" {
+		fmt.Println("Non map result should not be changed by middleware.")
+		t.Fail()
+	}
+}
+
+func TestMessageCodeTransformerDeclaresDataPath(t *testing.T) {
+	SetEnvelope(MessageCodeTransformer("payload", "code"))
+	defer ResetTransformer()
+	if path := DataPath(http.StatusOK); !reflect.DeepEqual(path, []string{"payload"}) {
+		fmt.Printf("Expected data path [payload], got %v\n", path)
+		t.Fail()
+	}
+}
@@ -0,0 +1,412 @@
+package jsonresponse
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// object is JSON object which keeps order of its fields. Response data is
+// converted to tree of objects, slices and plain values when it has to be
+// modified (e.g. filtered) before it is serialized.
+type object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newObject(size int) *object {
+	return &object{keys: make([]string, 0, size), values: make(map[string]interface{}, size)}
+}
+
+func (o *object) set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// MarshalJSON writes fields of object in order in which they were added.
+func (o *object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		kb, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(kb)
+		buf.WriteByte(':')
+		vb, err := json.Marshal(o.values[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(vb)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+var (
+	objectType        = reflect.TypeOf(&object{})
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// converter converts values to tree, applying redaction of sensitive fields
+// and filtering of fields by view while doing so.
+type converter struct {
+	redaction redactionOptions
+	naming    keyNaming
+	view      string
+	// shallow converts only top level value, leaving its fields as they are
+	shallow bool
+}
+
+// newConverter returns converter that uses provided snapshot of
+// configuration.
+func newConverter(view string, s *settings) *converter {
+	return &converter{redaction: s.redaction, naming: s.keyNaming, view: view}
+}
+
+// toTree converts value to tree which serializes to same JSON as value
+// itself, except for redacted fields and fields not visible without view.
+// Keys are not redacted by patterns (see SetRedactedKeys), since value is not
+// data of response, or data is wrapped in filtered.
+// Structs and maps are converted to objects, slices and arrays to
+// []interface{}. Values that implement json.Marshaler or encoding.TextMarshaler
+// and plain values are left as they are.
+func toTree(v interface{}, s *settings) interface{} {
+	c := &converter{redaction: s.redaction.withoutKeys(), naming: s.keyNaming}
+	return c.tree(v)
+}
+
+func (c *converter) tree(v interface{}) interface{} {
+	return c.convert(reflect.ValueOf(v))
+}
+
+func (c *converter) convert(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Type() == objectType {
+		return v.Interface()
+	}
+	if m, ok := marshalerOf(v); ok {
+		return m
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return c.convert(v.Elem())
+	case reflect.Struct:
+		return c.convertStruct(v)
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		return c.convertMap(v)
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return interfaceOf(v)
+		}
+		fallthrough
+	case reflect.Array:
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = c.convert(v.Index(i))
+		}
+		return items
+	}
+	return interfaceOf(v)
+}
+
+func (c *converter) convertStruct(v reflect.Value) interface{} {
+	fields := cachedFields(v.Type(), c.naming)
+	obj := newObject(len(fields))
+	for _, f := range fields {
+		if !f.visibleIn(c.view) {
+			continue
+		}
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok {
+			continue
+		}
+		if f.omitEmpty && isEmptyValue(fv) || f.omitZero && isZeroValue(fv) {
+			continue
+		}
+		if c.redaction.redacts(f.name, f.redact) {
+			if !c.redaction.drop {
+				obj.set(f.name, RedactedValue)
+			}
+			continue
+		}
+		if f.quoted {
+			obj.set(f.name, quote(fv))
+		} else {
+			obj.set(f.name, c.child(fv))
+		}
+	}
+	return obj
+}
+
+// child converts field of struct or value of map.
+func (c *converter) child(v reflect.Value) interface{} {
+	if c.shallow {
+		return interfaceOf(v)
+	}
+	return c.convert(v)
+}
+
+func (c *converter) convertMap(v reflect.Value) interface{} {
+	keys := make([]string, 0, v.Len())
+	values := make(map[string]interface{}, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		k, ok := mapKey(iter.Key())
+		if !ok {
+			// same as encoding/json, which fails on such keys
+			return interfaceOf(v)
+		}
+		if c.redaction.redacts(k, false) {
+			if !c.redaction.drop {
+				keys = append(keys, k)
+				values[k] = RedactedValue
+			}
+			continue
+		}
+		keys = append(keys, k)
+		values[k] = c.child(iter.Value())
+	}
+	sort.Strings(keys)
+	return &object{keys: keys, values: values}
+}
+
+// mapKey returns key of map as string, same way it is done by encoding/json.
+func mapKey(k reflect.Value) (string, bool) {
+	if k.Kind() == reflect.String && !textKeysFirst {
+		return k.String(), true
+	}
+	if k.Type().Implements(textMarshalerType) {
+		if k.Kind() == reflect.Ptr && k.IsNil() {
+			return "", true
+		}
+		if !k.CanInterface() {
+			return "", false
+		}
+		b, err := k.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), err == nil
+	}
+	switch k.Kind() {
+	case reflect.String:
+		return k.String(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), true
+	}
+	return "", false
+}
+
+// marshalerOf returns value that has to be serialized by encoding/json, since
+// it implements one of marshaler interfaces.
+func marshalerOf(v reflect.Value) (interface{}, bool) {
+	if !v.CanInterface() {
+		return nil, false
+	}
+	t := v.Type()
+	if t.Kind() != reflect.Ptr && v.CanAddr() {
+		pt := reflect.PtrTo(t)
+		if pt.Implements(marshalerType) || pt.Implements(textMarshalerType) {
+			return v.Addr().Interface(), true
+		}
+	}
+	if t.Implements(marshalerType) || t.Implements(textMarshalerType) {
+		return v.Interface(), true
+	}
+	return nil, false
+}
+
+// interfaceOf returns value as interface. Values obtained via unexported
+// embedded structs can not be used directly, so copy of them is made.
+func interfaceOf(v reflect.Value) interface{} {
+	if v.CanInterface() {
+		return v.Interface()
+	}
+	c := reflect.New(v.Type()).Elem()
+	switch v.Kind() {
+	case reflect.Bool:
+		c.SetBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		c.SetInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		c.SetUint(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		c.SetFloat(v.Float())
+	case reflect.String:
+		c.SetString(v.String())
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return append([]byte{}, v.Bytes()...)
+		}
+		return nil
+	default:
+		return nil
+	}
+	return c.Interface()
+}
+
+// quote returns value of field with ",string" option.
+func quote(v reflect.Value) interface{} {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	b, err := json.Marshal(interfaceOf(v))
+	if err != nil {
+		return nil
+	}
+	return string(b)
+}
+
+// fieldByIndex returns field of struct, or false if field is in embedded
+// struct referenced via nil pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+func isZeroValue(v reflect.Value) bool {
+	if v.CanInterface() {
+		if z, ok := v.Interface().(interface{ IsZero() bool }); ok {
+			if v.Kind() == reflect.Ptr && v.IsNil() {
+				return true
+			}
+			return z.IsZero()
+		}
+	}
+	return v.IsZero()
+}
+
+// needsConversion returns true if body might contain fields that have to be
+// redacted, filtered by view or renamed, so it has to be converted to tree before
+// serialization.
+func needsConversion(body interface{}, s *settings) bool {
+	if s.keyNaming.casing != KeepCase {
+		return true
+	}
+	return valueHasResponseTags(body)
+}
+
+func valueHasResponseTags(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case map[string]interface{}:
+		for _, item := range t {
+			if valueHasResponseTags(item) {
+				return true
+			}
+		}
+		return false
+	case []interface{}:
+		for _, item := range t {
+			if valueHasResponseTags(item) {
+				return true
+			}
+		}
+		return false
+	case *object:
+		for _, item := range t.values {
+			if valueHasResponseTags(item) {
+				return true
+			}
+		}
+		return false
+	}
+	return typeHasResponseTags(reflect.TypeOf(v))
+}
+
+var responseTagsCache sync.Map // map[reflect.Type]bool
+
+// typeHasResponseTags returns true if values of provided type can contain
+// fields with jsonresponse tag. Types which can contain any value (interfaces)
+// are reported as such.
+func typeHasResponseTags(t reflect.Type) bool {
+	if r, ok := responseTagsCache.Load(t); ok {
+		return r.(bool)
+	}
+	result := searchResponseTags(t, map[reflect.Type]bool{})
+	responseTagsCache.Store(t, result)
+	return result
+}
+
+// searchResponseTags searches all types reachable from provided one for fields
+// with jsonresponse tag. Only result for root type is complete (types that
+// are part of cycle are not searched again), so only it can be cached.
+func searchResponseTags(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if r, ok := responseTagsCache.Load(t); ok {
+		return r.(bool)
+	}
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	switch {
+	case t.Implements(marshalerType) || t.Implements(textMarshalerType):
+		return false
+	case t.Kind() == reflect.Interface:
+		return true
+	case t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map:
+		return searchResponseTags(t.Elem(), seen)
+	case t.Kind() == reflect.Struct:
+		// tags do not depend on naming of fields
+		for _, f := range cachedFields(t, keyNaming{}) {
+			if f.redact || len(f.views) > 0 || searchResponseTags(f.typ, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package jsonresponse
+
+import (
+	"context"
+	"net/http"
+)
+
+type viewContextKey struct{}
+
+// WithView returns context with view that is used for responses sent for
+// requests with that context (e.g. set by authentication middleware).
+// View of response set via Response.View has precedence over it.
+func WithView(ctx context.Context, view string) context.Context {
+	return context.WithValue(ctx, viewContextKey{}, view)
+}
+
+// ViewFromContext returns view stored in context by WithView, or empty
+// string if there is none.
+func ViewFromContext(ctx context.Context) string {
+	view, _ := ctx.Value(viewContextKey{}).(string)
+	return view
+}
+
+// View sets view in which data of response is rendered. Struct fields tagged
+// with views (e.g. `jsonresponse:"view=admin,owner"`) are included only if
+// their views contain view of response, and are omitted if no view is set.
+// Fields without views are always included. Only data is filtered, envelope
+// produced by transformer is not changed. Data is filtered after transformer
+// is called, so transformer receives it as it is.
+func (r Response) View(view string) Response {
+	r.view = view
+	return r
+}
+
+// viewFor returns view in which response is rendered for request.
+func (r Response) viewFor(req *http.Request) string {
+	if r.view != "" || req == nil {
+		return r.view
+	}
+	return ViewFromContext(req.Context())
+}
@@ -0,0 +1,107 @@
+package jsonresponse
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type viewUser struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email" jsonresponse:"view=admin,owner"`
+	Notes string `json:"notes" jsonresponse:"redact,view=admin"`
+	Role  string `json:"role" jsonresponse:"view=admin"`
+}
+
+var testViewUser = viewUser{ID: 1, Name: "foo", Email: "foo@example.com", Notes: "notes", Role: "user"}
+
+func TestViewTagParsing(t *testing.T) {
+	for tag, expected := range map[string][]string{
+		"":                        nil,
+		"redact":                  nil,
+		"view=admin":              []string{"admin"},
+		"view=admin,owner":        []string{"admin", "owner"},
+		"redact,view=admin,owner": []string{"admin", "owner"},
+		"view=admin,redact":       []string{"admin"},
+	} {
+		if views := responseTag(tag).views(); !reflect.DeepEqual(views, expected) {
+			fmt.Printf("Tag %q: expected views %#v but got %#v\n", tag, expected, views)
+			t.Fail()
+		}
+	}
+}
+
+func TestResponseView(t *testing.T) {
+	SetIndent(false)
+	for view, expected := range map[string]string{
+		"":       `{"data":{"id":1,"name":"foo"}}`,
+		"public": `{"data":{"id":1,"name":"foo"}}`,
+		"owner":  `{"data":{"id":1,"name":"foo","email":"foo@example.com"}}`,
+		"admin":  `{"data":{"id":1,"name":"foo","email":"foo@example.com","notes":"[REDACTED]","role":"user"}}`,
+	} {
+		recorder := httptest.NewRecorder()
+		New(testViewUser).View(view).OK(recorder)
+		if got := strings.TrimSpace(recorder.Body.String()); got != expected {
+			fmt.Printf("View %q: expected %s\nbut got  %s\n", view, expected, got)
+			t.Fail()
+		}
+	}
+}
+
+func TestViewFromRequestContext(t *testing.T) {
+	SetIndent(false)
+	request := httptest.NewRequest("GET", "/users/1", nil)
+	request = request.WithContext(WithView(request.Context(), "owner"))
+
+	recorder := httptest.NewRecorder()
+	New(testViewUser).OKFor(recorder, request)
+	expected := `{"data":{"id":1,"name":"foo","email":"foo@example.com"}}`
+	if got := strings.TrimSpace(recorder.Body.String()); got != expected {
+		fmt.Printf("Expected %s\nbut got  %s\n", expected, got)
+		t.Fail()
+	}
+
+	recorder = httptest.NewRecorder()
+	New(testViewUser).View("public").OKFor(recorder, request)
+	expected = `{"data":{"id":1,"name":"foo"}}`
+	if got := strings.TrimSpace(recorder.Body.String()); got != expected {
+		fmt.Printf("Response view should have precedence, expected %s\nbut got  %s\n", expected, got)
+		t.Fail()
+	}
+}
+
+func TestViewWithMessageCodeTransformer(t *testing.T) {
+	SetIndent(false)
+	SetEnvelope(MessageCodeTransformer("result", "status"))
+	defer ResetTransformer()
+
+	recorder := httptest.NewRecorder()
+	New(testViewUser).View("owner").OK(recorder)
+	expected := `{"result":{"id":1,"name":"foo","email":"foo@example.com"},"status":200}`
+	if got := strings.TrimSpace(recorder.Body.String()); got != expected {
+		fmt.Printf("Expected %s\nbut got  %s\n", expected, got)
+		t.Fail()
+	}
+}
+
+func TestViewWithRequestIDInBody(t *testing.T) {
+	SetIndent(false)
+	defer ResetTransformer()
+	SetRequestTransformer(RequestIDInBody(AdaptTransformer(PassthroughTransformer)))
+
+	request := httptest.NewRequest("GET", "/users/1", nil)
+	request = request.WithContext(ContextWithRequestID(request.Context(), "abc"))
+	for _, view := range []string{"", "public"} {
+		viewRequest := request.WithContext(WithView(request.Context(), view))
+		recorder := httptest.NewRecorder()
+		New(MessageResponse{Code: 404, Message: "nope"}).NotFoundFor(recorder, viewRequest)
+		expected := `{"code":404,"message":"nope","request_id":"abc"}`
+		if got := strings.TrimSpace(recorder.Body.String()); got != expected {
+			fmt.Printf("View %q: expected %s\nbut got  %s\n", view, expected, got)
+			t.Fail()
+		}
+	}
+}